@@ -2,6 +2,9 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
 
 	"github.com/koolii/go-monkey/token"
 )
@@ -14,9 +17,18 @@ import (
 
 // すべてのノードはNodeインターフェイスを実装する
 // TokenLiteral()はテスト・デバッグ用で利用する
+// Pos()はノードの最初の文字の位置、End()はノードの直後の位置(終端は含まない)
+// 文の末尾のセミコロンはノードの範囲に含まない(式を囲む括弧 ( ) はParenExpressionの範囲になる)
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
+	End() token.Position
+}
+
+// after 1文字の区切り記号 } ) ] の位置から、その直後の位置を求める
+func after(pos token.Position) token.Position {
+	return token.Position{Offset: pos.Offset + 1, Line: pos.Line, Column: pos.Column + 1}
 }
 
 // statementNode()はダミーでExpressionインターフェイス
@@ -45,6 +57,21 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+// Pos 最初の文の位置(文がない場合は無効な位置)
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 // バッファを作成、それぞれのXXXStatementのString()メソッドの戻り値をバッファに書き込む
 func (p *Program) String() string {
 	var out bytes.Buffer
@@ -63,6 +90,13 @@ type LetStatement struct {
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.Token.End
+}
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -91,6 +125,8 @@ type Identifier struct {
 // そもそもIdentifierはNodeの種類を少なくするため、変数束縛の名前を表現のために作っている
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 func (i *Identifier) String() string       { return i.Value }
 
 type ReturnStatement struct {
@@ -100,6 +136,13 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal } // return
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+}
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -122,6 +165,18 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position {
+	if es.Expression != nil {
+		return es.Expression.Pos()
+	}
+	return es.Token.Pos
+}
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -137,8 +192,64 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// FloatLiteral is for token.FLOAT
+type FloatLiteral struct {
+	Token token.Token // token.FLOAT
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+// StringLiteral is for token.STRING
+// Valueはエスケープシーケンスを解釈した後の文字列
+type StringLiteral struct {
+	Token token.Token // token.STRING
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+func (sl *StringLiteral) String() string       { return quote(sl.Value) }
+
+// quote 文字列をMonkeyの文字列リテラルの形に戻す
+// 字句解析器が解釈できるエスケープシーケンスだけを使う
+func quote(s string) string {
+	var out bytes.Buffer
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			if unicode.IsPrint(r) {
+				out.WriteRune(r)
+			} else {
+				fmt.Fprintf(&out, `\u{%X}`, r)
+			}
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -147,6 +258,8 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return pe.Right.End() }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -165,6 +278,8 @@ type InfixExpression struct {
 
 func (oe *InfixExpression) expressionNode()      {}
 func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
+func (oe *InfixExpression) Pos() token.Position  { return oe.Left.Pos() }
+func (oe *InfixExpression) End() token.Position  { return oe.Right.End() }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -174,3 +289,369 @@ func (oe *InfixExpression) String() string {
 	out.WriteString(")")
 	return out.String()
 }
+
+// AssignExpression x += 1 などの複合代入式
+// 代入先は識別子に限られる
+type AssignExpression struct {
+	Token    token.Token // += -= *= /= のトークン
+	Name     *Identifier
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Name.Pos() }
+func (ae *AssignExpression) End() token.Position  { return ae.Value.End() }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Name.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// Boolean is for token.TRUE/token.FALSE
+type Boolean struct {
+	Token token.Token
+	Value bool
+}
+
+func (b *Boolean) expressionNode()      {}
+func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
+func (b *Boolean) String() string       { return b.Token.Literal }
+
+// IfExpression if (<condition>) <consequence> else <alternative>
+// Monkeyのifは式なので値を生成する。elseは省略できる
+type IfExpression struct {
+	Token       token.Token // token.IF
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (ie *IfExpression) expressionNode()      {}
+func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return ie.Consequence.End()
+}
+func (ie *IfExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("if (")
+	out.WriteString(ie.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(bracedBlock(ie.Consequence))
+
+	if ie.Alternative != nil {
+		out.WriteString(" else ")
+		out.WriteString(bracedBlock(ie.Alternative))
+	}
+
+	return out.String()
+}
+
+// bracedBlock ブロックを { } で囲み、文を ; で区切った文字列にする
+// if式や関数リテラルの文字列をMonkeyのコードとして読み直せるようにするため
+func bracedBlock(bs *BlockStatement) string {
+	if len(bs.Statements) == 0 {
+		return "{}"
+	}
+	statements := make([]string, len(bs.Statements))
+	for i, s := range bs.Statements {
+		statements[i] = strings.TrimSuffix(s.String(), ";")
+	}
+	return "{ " + strings.Join(statements, "; ") + " }"
+}
+
+// ParenExpression ( <expression> )
+// 評価や優先順位は中の式と同じで、括弧の位置をノードの範囲に含めるためにある
+type ParenExpression struct {
+	Token      token.Token // token.LPAREN
+	Expression Expression
+	Rparen     token.Position // ) の位置
+}
+
+func (pe *ParenExpression) expressionNode()      {}
+func (pe *ParenExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *ParenExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *ParenExpression) End() token.Position {
+	if pe.Rparen.IsValid() {
+		return after(pe.Rparen)
+	}
+	return pe.Expression.End()
+}
+
+// 中置演算子などのString()は既に括弧で囲まれているので、括弧を重ねずに中の式をそのまま出力する
+func (pe *ParenExpression) String() string { return pe.Expression.String() }
+
+// BlockStatement { から } までの一連の文
+type BlockStatement struct {
+	Token      token.Token // token.LBRACE
+	Statements []Statement
+	Rbrace     token.Position // } の位置
+}
+
+func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position {
+	if bs.Rbrace.IsValid() {
+		return after(bs.Rbrace)
+	}
+	// } がないまま入力が終わった
+	if len(bs.Statements) > 0 {
+		return bs.Statements[len(bs.Statements)-1].End()
+	}
+	return bs.Token.End
+}
+func (bs *BlockStatement) String() string {
+	var out bytes.Buffer
+
+	for _, s := range bs.Statements {
+		out.WriteString(s.String())
+	}
+
+	return out.String()
+}
+
+// FunctionLiteral fn(<parameters>) <block statement>
+type FunctionLiteral struct {
+	Token      token.Token // token.FUNCTION
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position  { return fl.Body.End() }
+func (fl *FunctionLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range fl.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(fl.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(bracedBlock(fl.Body))
+
+	return out.String()
+}
+
+// CallExpression <expression>(<comma separated expressions>)
+// Functionは識別子か関数リテラル、または関数を返す式
+type CallExpression struct {
+	Token     token.Token // token.LPAREN
+	Function  Expression
+	Arguments []Expression
+	Rparen    token.Position // ) の位置
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Position {
+	if ce.Rparen.IsValid() {
+		return after(ce.Rparen)
+	}
+	return ce.Token.End
+}
+func (ce *CallExpression) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range ce.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(ce.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// ArrayLiteral [<comma separated expressions>]
+type ArrayLiteral struct {
+	Token    token.Token // token.LBRACKET
+	Elements []Expression
+	Rbracket token.Position // ] の位置
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position {
+	if al.Rbracket.IsValid() {
+		return after(al.Rbracket)
+	}
+	return al.Token.End
+}
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// IndexExpression <expression>[<expression>]
+type IndexExpression struct {
+	Token    token.Token // token.LBRACKET
+	Left     Expression
+	Index    Expression
+	Rbracket token.Position // ] の位置
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position {
+	if ie.Rbracket.IsValid() {
+		return after(ie.Rbracket)
+	}
+	return ie.Token.End
+}
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// SliceExpression <expression>[<low>:<high>]
+// LowとHighは省略でき、省略した場合はnilになる
+type SliceExpression struct {
+	Token    token.Token // token.LBRACKET
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Rbracket token.Position // ] の位置
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Left.Pos() }
+func (se *SliceExpression) End() token.Position {
+	if se.Rbracket.IsValid() {
+		return after(se.Rbracket)
+	}
+	return se.Token.End
+}
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// HashLiteral {<key>: <value>, ...}
+// 式の中の { は常にハッシュリテラルになる(ブロックはifとfnの後にしか現れない)
+// Pairsはソースコードに書かれた順に並ぶ
+type HashLiteral struct {
+	Token  token.Token // token.LBRACE
+	Pairs  []*HashPair
+	Rbrace token.Position // } の位置
+}
+
+// HashPair ハッシュリテラルのキーと値の組
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position {
+	if hl.Rbrace.IsValid() {
+		return after(hl.Rbrace)
+	}
+	return hl.Token.End
+}
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// BadStatement 構文エラーのために解析できなかった文
+// 構文解析器はエラーから回復した後もASTを作り続けるので、その場所に置かれる
+type BadStatement struct {
+	Token token.Token    // 解析できなかった文の最初のトークン
+	To    token.Position // 読み飛ばした最後のトークンの直後の位置
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BadStatement) End() token.Position {
+	if bs.To.IsValid() {
+		return bs.To
+	}
+	return bs.Token.End
+}
+func (bs *BadStatement) String() string { return "<bad statement>" }
+
+// BadExpression 構文エラーのために解析できなかった式
+type BadExpression struct {
+	Token token.Token    // 解析できなかった式の最初のトークン
+	To    token.Position // エラーになったトークンの直後の位置
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) Pos() token.Position  { return be.Token.Pos }
+func (be *BadExpression) End() token.Position {
+	if be.To.IsValid() {
+		return be.To
+	}
+	return be.Token.End
+}
+func (be *BadExpression) String() string { return "<bad expression>" }
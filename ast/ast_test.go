@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/koolii/go-monkey/token"
@@ -21,3 +23,678 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestStringLiteralString(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"hello", `"hello"`},
+		{"say \"hi\"", `"say \"hi\""`},
+		{"a\\b", `"a\\b"`},
+		{"line\nnext\ttab\r", `"line\nnext\ttab\r"`},
+		{"日本語", `"日本語"`},
+		{"\x00\u200b", `"\u{0}\u{200B}"`},
+	}
+
+	for _, tt := range tests {
+		sl := &StringLiteral{Token: token.Token{Type: token.STRING, Literal: tt.value}, Value: tt.value}
+		if sl.String() != tt.expected {
+			t.Errorf("sl.String() wrong. expected=%q, got=%q", tt.expected, sl.String())
+		}
+	}
+}
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func integer(value int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(value)}, Value: value}
+}
+
+// preorder Inspectで訪れたノードのString()を行きがけ順に集める
+func preorder(node Node) []string {
+	var visited []string
+	Inspect(node, func(n Node) bool {
+		if n != nil {
+			visited = append(visited, n.String())
+		}
+		return true
+	})
+	return visited
+}
+
+func TestWalkVisitsEveryChild(t *testing.T) {
+	tests := []struct {
+		node     Node
+		expected []string
+	}{
+		// let x = a + -b;
+		{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  ident("x"),
+				Value: &InfixExpression{
+					Token:    token.Token{Type: token.PLUS, Literal: "+"},
+					Left:     ident("a"),
+					Operator: "+",
+					Right: &PrefixExpression{
+						Token:    token.Token{Type: token.MINUS, Literal: "-"},
+						Operator: "-",
+						Right:    ident("b"),
+					},
+				},
+			},
+			[]string{"let x = (a + (-b));", "x", "(a + (-b))", "a", "(-b)", "b"},
+		},
+		// return !1 * 2;
+		{
+			&ReturnStatement{
+				Token: token.Token{Type: token.RETURN, Literal: "return"},
+				ReturnValue: &InfixExpression{
+					Token:    token.Token{Type: token.ASTERISK, Literal: "*"},
+					Left:     &PrefixExpression{Token: token.Token{Type: token.BANG, Literal: "!"}, Operator: "!", Right: integer(1)},
+					Operator: "*",
+					Right:    integer(2),
+				},
+			},
+			[]string{"return ((!1) * 2);", "((!1) * 2)", "(!1)", "1", "2"},
+		},
+		// 値のないreturnは子ノードを持たない
+		{
+			&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}},
+			[]string{"return ;"},
+		},
+		// 省略されたスライスの範囲は訪れない
+		{
+			&SliceExpression{Token: token.Token{Type: token.LBRACKET, Literal: "["}, Left: ident("a"), High: integer(2)},
+			[]string{"(a[:2])", "a", "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		visited := preorder(tt.node)
+		if strings.Join(visited, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("visited wrong.\nexpected=%q\ngot=%q", tt.expected, visited)
+		}
+	}
+}
+
+// eventVisitor 行きがけと帰りがけの順序を記録する
+type eventVisitor struct {
+	events *[]string
+	stack  *[]Node
+}
+
+func (v eventVisitor) Visit(node Node) Visitor {
+	if node == nil {
+		n := (*v.stack)[len(*v.stack)-1]
+		*v.stack = (*v.stack)[:len(*v.stack)-1]
+		*v.events = append(*v.events, "leave "+n.String())
+		return nil
+	}
+	*v.stack = append(*v.stack, node)
+	*v.events = append(*v.events, "enter "+node.String())
+	return v
+}
+
+func TestWalkPostOrder(t *testing.T) {
+	// -(1 + 2)
+	node := &PrefixExpression{
+		Token:    token.Token{Type: token.MINUS, Literal: "-"},
+		Operator: "-",
+		Right: &InfixExpression{
+			Token:    token.Token{Type: token.PLUS, Literal: "+"},
+			Left:     integer(1),
+			Operator: "+",
+			Right:    integer(2),
+		},
+	}
+
+	var events []string
+	var stack []Node
+	Walk(eventVisitor{events: &events, stack: &stack}, node)
+
+	expected := []string{
+		"enter (-(1 + 2))",
+		"enter (1 + 2)",
+		"enter 1",
+		"leave 1",
+		"enter 2",
+		"leave 2",
+		"leave (1 + 2)",
+		"leave (-(1 + 2))",
+	}
+	if strings.Join(events, "|") != strings.Join(expected, "|") {
+		t.Errorf("events wrong.\nexpected=%q\ngot=%q", expected, events)
+	}
+}
+
+func TestInspectSkipsSubtree(t *testing.T) {
+	// f(1 + 2, 3)
+	node := &CallExpression{
+		Token:    token.Token{Type: token.LPAREN, Literal: "("},
+		Function: ident("f"),
+		Arguments: []Expression{
+			&InfixExpression{Token: token.Token{Type: token.PLUS, Literal: "+"}, Left: integer(1), Operator: "+", Right: integer(2)},
+			integer(3),
+		},
+	}
+
+	var visited []string
+	Inspect(node, func(n Node) bool {
+		if n == nil {
+			return false
+		}
+		visited = append(visited, n.String())
+		// 中置演算子の子ノードは訪れない
+		_, isInfix := n.(*InfixExpression)
+		return !isInfix
+	})
+
+	expected := []string{"f((1 + 2), 3)", "f", "(1 + 2)", "3"}
+	if strings.Join(visited, "|") != strings.Join(expected, "|") {
+		t.Errorf("visited wrong.\nexpected=%q\ngot=%q", expected, visited)
+	}
+}
+
+func TestWalkSkipsNilChildren(t *testing.T) {
+	// 手で組み立てた不完全なAST(子ノードがnilや型付きのnil)
+	tests := []struct {
+		node     Node
+		expected []string
+	}{
+		{&InfixExpression{Operator: "+", Right: integer(1)}, []string{"InfixExpression", "IntegerLiteral"}},
+		{&PrefixExpression{Operator: "-"}, []string{"PrefixExpression"}},
+		{&IfExpression{Consequence: &BlockStatement{}}, []string{"IfExpression", "BlockStatement"}},
+		{&CallExpression{Arguments: []Expression{nil, integer(1)}}, []string{"CallExpression", "IntegerLiteral"}},
+		{&IndexExpression{Left: (*Identifier)(nil), Index: integer(1)}, []string{"IndexExpression", "IntegerLiteral"}},
+		{&FunctionLiteral{Parameters: []*Identifier{nil}}, []string{"FunctionLiteral"}},
+		{&HashLiteral{Pairs: []*HashPair{nil, {Key: nil, Value: integer(1)}}}, []string{"HashLiteral", "IntegerLiteral"}},
+		{&Program{Statements: []Statement{nil, &ExpressionStatement{}}}, []string{"Program", "ExpressionStatement"}},
+	}
+
+	for _, tt := range tests {
+		var visited []string
+		depth := 0
+		Inspect(tt.node, func(n Node) bool {
+			// nilは子ノードを訪れ終わった知らせとしてだけ届く
+			if n == nil {
+				depth--
+				return false
+			}
+			visited = append(visited, nodeTypeName(n))
+			depth++
+			return true
+		})
+
+		if strings.Join(visited, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%T: visited wrong.\nexpected=%q\ngot=%q", tt.node, tt.expected, visited)
+		}
+		if depth != 0 {
+			t.Errorf("%T: enter and leave do not match. depth=%d", tt.node, depth)
+		}
+	}
+}
+
+func TestWalkCoversAllNodeTypes(t *testing.T) {
+	block := func(stmts ...Statement) *BlockStatement {
+		return &BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Statements: stmts}
+	}
+	exprStmt := func(e Expression) *ExpressionStatement {
+		return &ExpressionStatement{Expression: e}
+	}
+
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: ident("x"), Value: &FloatLiteral{Value: 1.5}},
+		&ReturnStatement{ReturnValue: &StringLiteral{Value: "s"}},
+		exprStmt(&IfExpression{
+			Condition:   &Boolean{Value: true},
+			Consequence: block(exprStmt(&PrefixExpression{Operator: "-", Right: integer(1)})),
+			Alternative: block(exprStmt(&InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)})),
+		}),
+		exprStmt(&CallExpression{
+			Function:  &FunctionLiteral{Parameters: []*Identifier{ident("a")}, Body: block(exprStmt(&AssignExpression{Name: ident("a"), Operator: "+=", Value: integer(1)}))},
+			Arguments: []Expression{&ArrayLiteral{Elements: []Expression{integer(1)}}},
+		}),
+		exprStmt(&IndexExpression{Left: &HashLiteral{Pairs: []*HashPair{{Key: integer(1), Value: integer(2)}}}, Index: integer(1)}),
+		exprStmt(&SliceExpression{Left: ident("a"), Low: integer(0), High: integer(1)}),
+		exprStmt(&ParenExpression{Expression: ident("a")}),
+		&BadStatement{},
+		exprStmt(&BadExpression{}),
+	}}
+
+	seen := map[string]bool{}
+	Inspect(program, func(n Node) bool {
+		if n != nil {
+			seen[strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")] = true
+		}
+		return true
+	})
+
+	for _, name := range []string{
+		"Program", "LetStatement", "ReturnStatement", "ExpressionStatement", "BlockStatement",
+		"Identifier", "IntegerLiteral", "FloatLiteral", "StringLiteral", "Boolean",
+		"PrefixExpression", "InfixExpression", "AssignExpression", "IfExpression",
+		"FunctionLiteral", "CallExpression", "ArrayLiteral", "IndexExpression",
+		"SliceExpression", "HashLiteral", "ParenExpression", "BadStatement", "BadExpression",
+	} {
+		if !seen[name] {
+			t.Errorf("%s was not visited", name)
+		}
+	}
+}
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return integer(1) }
+	two := func() Expression { return integer(2) }
+	block := func(e Expression) *BlockStatement {
+		return &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: e}}}
+	}
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		integer.Token.Literal = "2"
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected string
+	}{
+		{one(), "2"},
+		{&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}}, "2"},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, "(2 + 2)"},
+		{&InfixExpression{Left: two(), Operator: "+", Right: one()}, "(2 + 2)"},
+		{&PrefixExpression{Operator: "-", Right: one()}, "(-2)"},
+		{&IndexExpression{Left: one(), Index: one()}, "(2[2])"},
+		{&SliceExpression{Left: one(), High: one()}, "(2[:2])"},
+		{&IfExpression{Condition: one(), Consequence: block(one()), Alternative: block(one())}, "if (2) { 2 } else { 2 }"},
+		{&ReturnStatement{Token: token.Token{Literal: "return"}, ReturnValue: one()}, "return 2;"},
+		{&LetStatement{Token: token.Token{Literal: "let"}, Name: ident("x"), Value: one()}, "let x = 2;"},
+		{&FunctionLiteral{Token: token.Token{Literal: "fn"}, Parameters: []*Identifier{ident("x")}, Body: block(one())}, "fn(x) { 2 }"},
+		{&CallExpression{Function: ident("f"), Arguments: []Expression{one(), two()}}, "f(2, 2)"},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, "[2, 2]"},
+		{&HashLiteral{Pairs: []*HashPair{{Key: one(), Value: one()}}}, "{2: 2}"},
+		{&AssignExpression{Name: ident("x"), Operator: "+=", Value: one()}, "(x += 2)"},
+	}
+
+	for _, tt := range tests {
+		modified, err := Modify(tt.input, turnOneIntoTwo)
+		if err != nil {
+			t.Errorf("Modify(%s) returned error: %s", tt.input, err)
+			continue
+		}
+		if modified.String() != tt.expected {
+			t.Errorf("not modified. expected=%q, got=%q", tt.expected, modified.String())
+		}
+	}
+}
+
+func TestModifyConstantFolding(t *testing.T) {
+	// let x = 1 + 2 * 3; を let x = 7; に畳み込む
+	program := &Program{Statements: []Statement{
+		&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  ident("x"),
+			Value: &InfixExpression{
+				Left:     integer(1),
+				Operator: "+",
+				Right:    &InfixExpression{Left: integer(2), Operator: "*", Right: integer(3)},
+			},
+		},
+	}}
+
+	fold := func(node Node) Node {
+		infix, ok := node.(*InfixExpression)
+		if !ok {
+			return node
+		}
+		left, ok := infix.Left.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+		right, ok := infix.Right.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+		switch infix.Operator {
+		case "+":
+			return integer(left.Value + right.Value)
+		case "*":
+			return integer(left.Value * right.Value)
+		}
+		return node
+	}
+
+	modified, err := Modify(program, fold)
+	if err != nil {
+		t.Fatalf("Modify returned error: %s", err)
+	}
+	if modified.String() != "let x = 7;" {
+		t.Errorf("not folded. got=%q", modified.String())
+	}
+}
+
+func TestModifyWrongKind(t *testing.T) {
+	toStatement := func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok && integer.Value == 1 {
+			return &ExpressionStatement{Expression: integer}
+		}
+		return node
+	}
+	toExpression := func(node Node) Node {
+		if stmt, ok := node.(*ExpressionStatement); ok {
+			return stmt.Expression
+		}
+		return node
+	}
+	toInteger := func(node Node) Node {
+		if _, ok := node.(*Identifier); ok {
+			return integer(1)
+		}
+		return node
+	}
+	toNil := func(node Node) Node {
+		if _, ok := node.(*IntegerLiteral); ok {
+			return nil
+		}
+		return node
+	}
+	toTypedNil := func(node Node) Node {
+		if _, ok := node.(*IntegerLiteral); ok {
+			return (*IntegerLiteral)(nil)
+		}
+		return node
+	}
+
+	tests := []struct {
+		input    Node
+		modifier ModifierFunc
+		expected string
+	}{
+		{
+			&InfixExpression{Left: integer(2), Operator: "+", Right: integer(1)},
+			toStatement,
+			"ast.Modify: InfixExpression.Right must be an Expression, got *ast.ExpressionStatement",
+		},
+		{
+			&Program{Statements: []Statement{&LetStatement{Name: ident("x"), Value: integer(2)}, &ExpressionStatement{Expression: integer(2)}}},
+			toExpression,
+			"ast.Modify: Program.Statements[1] must be a Statement, got *ast.IntegerLiteral",
+		},
+		{
+			&LetStatement{Name: ident("x"), Value: integer(2)},
+			toInteger,
+			"ast.Modify: LetStatement.Name must be an *ast.Identifier, got *ast.IntegerLiteral",
+		},
+		{
+			&FunctionLiteral{Parameters: []*Identifier{ident("x")}, Body: &BlockStatement{}},
+			toInteger,
+			"ast.Modify: FunctionLiteral.Parameters[0] must be an *ast.Identifier, got *ast.IntegerLiteral",
+		},
+		{
+			&ReturnStatement{ReturnValue: &PrefixExpression{Operator: "-", Right: integer(2)}},
+			toNil,
+			"ast.Modify: PrefixExpression.Right must be an Expression, got nil",
+		},
+		// 型付きのnilポインタもnilとして扱う
+		{
+			&InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)},
+			toTypedNil,
+			"ast.Modify: InfixExpression.Left must be an Expression, got nil *ast.IntegerLiteral",
+		},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: ident("x")}}},
+			func(node Node) Node {
+				if _, ok := node.(*ExpressionStatement); ok {
+					return (*ExpressionStatement)(nil)
+				}
+				return node
+			},
+			"ast.Modify: Program.Statements[0] must be a Statement, got nil *ast.ExpressionStatement",
+		},
+		{
+			&LetStatement{Name: ident("x"), Value: integer(2)},
+			func(node Node) Node {
+				if _, ok := node.(*Identifier); ok {
+					return (*Identifier)(nil)
+				}
+				return node
+			},
+			"ast.Modify: LetStatement.Name must be an *ast.Identifier, got nil *ast.Identifier",
+		},
+		{
+			&IfExpression{Condition: ident("x"), Consequence: &BlockStatement{}},
+			func(node Node) Node {
+				if _, ok := node.(*BlockStatement); ok {
+					return (*BlockStatement)(nil)
+				}
+				return node
+			},
+			"ast.Modify: IfExpression.Consequence must be an *ast.BlockStatement, got nil *ast.BlockStatement",
+		},
+		{
+			integer(1),
+			toTypedNil,
+			"ast.Modify: result must be a Node, got nil *ast.IntegerLiteral",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := Modify(tt.input, tt.modifier)
+		if err == nil {
+			t.Errorf("Modify(%s) - expected error. got none", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("error wrong.\nexpected=%q\ngot=%q", tt.expected, err.Error())
+		}
+	}
+
+	// エラーになった場所には元のノードが残る
+	infix := &InfixExpression{Left: integer(2), Operator: "+", Right: integer(1)}
+	Modify(infix, toStatement)
+	if infix.String() != "(2 + 1)" {
+		t.Errorf("original node not kept. got=%q", infix.String())
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	x := &Identifier{
+		Token: token.Token{
+			Type: token.IDENT, Literal: "x",
+			Pos: token.Position{Offset: 4, Line: 1, Column: 5},
+			End: token.Position{Offset: 5, Line: 1, Column: 6},
+		},
+		Value: "x",
+	}
+	stmt := &ReturnStatement{
+		Token: token.Token{
+			Type: token.RETURN, Literal: "return",
+			Pos: token.Position{Offset: 0, Line: 1, Column: 1},
+			End: token.Position{Offset: 6, Line: 1, Column: 7},
+		},
+		ReturnValue: &PrefixExpression{Token: token.Token{Type: token.MINUS, Literal: "-"}, Operator: "-", Right: x},
+	}
+
+	data, err := EncodeJSON(stmt.ReturnValue.(*PrefixExpression).Right)
+	if err != nil {
+		t.Fatalf("EncodeJSON failed: %v", err)
+	}
+	expected := `{"end":{"offset":5,"line":1,"column":6},"pos":{"offset":4,"line":1,"column":5},` +
+		`"token":{"type":"IDENT","literal":"x","pos":{"offset":4,"line":1,"column":5},"end":{"offset":5,"line":1,"column":6}},` +
+		`"type":"Identifier","value":"x"}`
+	if string(data) != expected {
+		t.Errorf("EncodeJSON wrong.\nexpected=%s\ngot=%s", expected, data)
+	}
+
+	// 省略された子ノードはフィールドごと出力しない
+	data, err = EncodeJSON(&ReturnStatement{Token: stmt.Token})
+	if err != nil {
+		t.Fatalf("EncodeJSON failed: %v", err)
+	}
+	if strings.Contains(string(data), "returnValue") {
+		t.Errorf("EncodeJSON wrote a missing returnValue. got=%s", data)
+	}
+
+	node, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("DecodeJSON failed: %v", err)
+	}
+	rs, ok := node.(*ReturnStatement)
+	if !ok {
+		t.Fatalf("node is not *ReturnStatement. got=%T", node)
+	}
+	if rs.ReturnValue != nil || rs.Token != stmt.Token {
+		t.Errorf("DecodeJSON wrong. got=%+v", rs)
+	}
+}
+
+func TestEncodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    Node
+		expected string
+	}{
+		{nil, "ast.EncodeJSON: root must be a node, got nil"},
+		{(*Identifier)(nil), "ast.EncodeJSON: root must be a node, got nil"},
+		{&LetStatement{}, "ast.EncodeJSON: root(LetStatement).name must be a node, got nil"},
+		{&LetStatement{Name: ident("x")}, "ast.EncodeJSON: root(LetStatement).value must be a node, got nil"},
+		{&InfixExpression{Left: integer(1), Operator: "+"}, "ast.EncodeJSON: root(InfixExpression).right must be a node, got nil"},
+		{&FunctionLiteral{Parameters: []*Identifier{ident("a")}}, "ast.EncodeJSON: root(FunctionLiteral).body must be a node, got nil"},
+		{&IfExpression{Condition: ident("x")}, "ast.EncodeJSON: root(IfExpression).consequence must be a node, got nil"},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: ident("x")}, (*LetStatement)(nil)}},
+			"ast.EncodeJSON: root(Program).statements[1] must be a node, got nil",
+		},
+		{
+			&HashLiteral{Pairs: []*HashPair{{Key: integer(1)}}},
+			"ast.EncodeJSON: root(HashLiteral).pairs[0].value must be a node, got nil",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := EncodeJSON(tt.input)
+		if err == nil {
+			t.Errorf("EncodeJSON(%#v) - expected error. got none", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("error wrong.\nexpected=%q\ngot=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tok := `"token":{"type":"IDENT","literal":"x","pos":{"offset":0,"line":1,"column":1},"end":{"offset":1,"line":1,"column":2}}`
+	x := `{"type":"Identifier",` + tok + `,"value":"x"}`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`null`, "ast.DecodeJSON: root must be a node, got null"},
+		{`[]`, "ast.DecodeJSON: root: json: cannot unmarshal array"},
+		{`{"statements":[]}`, `ast.DecodeJSON: root: missing field "type"`},
+		{`{"type":"WhileStatement",` + tok + `}`, `ast.DecodeJSON: root(WhileStatement): unknown node type "WhileStatement"`},
+		{`{"type":"Identifier","value":"x"}`, `ast.DecodeJSON: root(Identifier): missing field "token"`},
+		{`{"type":"Identifier",` + tok + `,"value":1}`,
+			"ast.DecodeJSON: root(Identifier).value: json: cannot unmarshal number"},
+		{`{"type":"Program","statements":[` + x + `]}`,
+			"ast.DecodeJSON: root(Program).statements[0] must be a Statement, got Identifier"},
+		{`{"type":"PrefixExpression",` + tok + `,"operator":"-","right":null}`,
+			"ast.DecodeJSON: root(PrefixExpression).right must be a node, got null"},
+		{`{"type":"LetStatement",` + tok + `,"name":{"type":"IntegerLiteral",` + tok + `,"value":1},"value":` + x + `}`,
+			"ast.DecodeJSON: root(LetStatement).name must be an *ast.Identifier, got IntegerLiteral"},
+		{`{"type":"FunctionLiteral",` + tok + `,"parameters":[],"body":` + x + `}`,
+			"ast.DecodeJSON: root(FunctionLiteral).body must be an *ast.BlockStatement, got Identifier"},
+	}
+
+	for _, tt := range tests {
+		_, err := DecodeJSON([]byte(tt.input))
+		if err == nil {
+			t.Errorf("input %s: expected error %q, got nil", tt.input, tt.expected)
+			continue
+		}
+		// encoding/jsonのメッセージの後半はGoのバージョンで変わるので前方一致で比べる
+		if !strings.HasPrefix(err.Error(), tt.expected) {
+			t.Errorf("input %s: error wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	// let x = -(1 + 2) * "a\"b";
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  ident("x"),
+				Value: &InfixExpression{
+					Token:    token.Token{Type: token.ASTERISK, Literal: "*"},
+					Operator: "*",
+					Left: &PrefixExpression{
+						Token:    token.Token{Type: token.MINUS, Literal: "-"},
+						Operator: "-",
+						Right: &InfixExpression{
+							Token:    token.Token{Type: token.PLUS, Literal: "+"},
+							Left:     integer(1),
+							Operator: "+",
+							Right:    integer(2),
+						},
+					},
+					Right: &StringLiteral{Token: token.Token{Type: token.STRING, Literal: `a"b`}, Value: `a"b`},
+				},
+			},
+			&ExpressionStatement{Token: token.Token{Type: token.IDENT, Literal: "x"}, Expression: ident("x")},
+		},
+	}
+
+	var tree strings.Builder
+	if err := WriteTree(&tree, program); err != nil {
+		t.Fatalf("WriteTree failed: %v", err)
+	}
+	expected := "Program\n" +
+		"|-- LetStatement \"let\"\n" +
+		"|   |-- Identifier \"x\"\n" +
+		"|   `-- InfixExpression \"*\"\n" +
+		"|       |-- PrefixExpression \"-\"\n" +
+		"|       |   `-- InfixExpression \"+\"\n" +
+		"|       |       |-- IntegerLiteral \"1\"\n" +
+		"|       |       `-- IntegerLiteral \"2\"\n" +
+		"|       `-- StringLiteral \"a\\\"b\"\n" +
+		"`-- ExpressionStatement \"x\"\n" +
+		"    `-- Identifier \"x\"\n"
+	if tree.String() != expected {
+		t.Errorf("WriteTree wrong.\nexpected=\n%s\ngot=\n%s", expected, tree.String())
+	}
+
+	var dot strings.Builder
+	if err := WriteDOT(&dot, program.Statements[0].(*LetStatement).Value); err != nil {
+		t.Fatalf("WriteDOT failed: %v", err)
+	}
+	expected = `digraph AST {
+	ordering=out;
+	node [shape=box, fontname="monospace"];
+	n0 [label="InfixExpression \"*\""];
+	n1 [label="PrefixExpression \"-\""];
+	n0 -> n1;
+	n2 [label="InfixExpression \"+\""];
+	n1 -> n2;
+	n3 [label="IntegerLiteral \"1\""];
+	n2 -> n3;
+	n4 [label="IntegerLiteral \"2\""];
+	n2 -> n4;
+	n5 [label="StringLiteral \"a\\\"b\""];
+	n0 -> n5;
+}
+`
+	if dot.String() != expected {
+		t.Errorf("WriteDOT wrong.\nexpected=\n%s\ngot=\n%s", expected, dot.String())
+	}
+}
@@ -0,0 +1,627 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/koolii/go-monkey/token"
+)
+
+// ASTのJSON形式
+//
+// すべてのノードは次の形のオブジェクトになる
+// "type" はノードの型名(LetStatement, InfixExpression など)
+// "pos" と "end" は Pos() と End() の値で、デコード時には読み込まない(他のフィールドから決まる)
+//
+//	{
+//	  "type": "InfixExpression",
+//	  "token": {"type": "+", "literal": "+", "pos": {...}, "end": {...}},
+//	  "pos": {"offset": 0, "line": 1, "column": 1},
+//	  "end": {"offset": 5, "line": 1, "column": 6},
+//	  "operator": "+",
+//	  "left": {...},
+//	  "right": {...}
+//	}
+//
+// Programは "token" を持たない
+// 省略できる子ノード(elseやスライスの範囲など)がない場合はフィールド自体を出力しない
+// 子ノードのフィールド名は構造体のフィールド名を小文字始まりにしたもの
+
+// jsonPosition token.Positionのjson形式
+type jsonPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// jsonToken token.Tokenのjson形式
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Pos     jsonPosition    `json:"pos"`
+	End     jsonPosition    `json:"end"`
+}
+
+func encodePosition(pos token.Position) jsonPosition {
+	return jsonPosition{Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+}
+
+func decodePosition(pos jsonPosition) token.Position {
+	return token.Position{Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+}
+
+func encodeToken(tok token.Token) jsonToken {
+	return jsonToken{Type: tok.Type, Literal: tok.Literal, Pos: encodePosition(tok.Pos), End: encodePosition(tok.End)}
+}
+
+func decodeToken(tok jsonToken) token.Token {
+	return token.Token{Type: tok.Type, Literal: tok.Literal, Pos: decodePosition(tok.Pos), End: decodePosition(tok.End)}
+}
+
+// EncodeJSON nodeとその子ノードをJSONにする
+// Programに限らず、どのノードからでも出力できる
+// 省略できない子ノードがnilの場合(手で組み立てた不完全なASTなど)はエラーを返す
+func EncodeJSON(node Node) ([]byte, error) {
+	obj, err := encodeNode(node, "root")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
+// DecodeJSON EncodeJSONが出力したJSONからノードを組み立て直す
+// 戻り値の型は "type" で決まる(Programなら*Program)
+func DecodeJSON(data []byte) (Node, error) {
+	node, err := decodeNode(data, "root")
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("ast.DecodeJSON: root must be a node, got null")
+	}
+	return node, nil
+}
+
+// encodeNode ノードをjson.Marshalに渡せるmapにする
+// mapのキーはjson.Marshalがソートするので、出力は常に同じ順番になる
+// pathはエラーメッセージで使う、ルートからのフィールド名
+// 省略できないフィールドがnil(型付きのnilポインタも含む)の場合はエラーになる
+func encodeNode(node Node, path string) (map[string]interface{}, error) {
+	if isNil(node) {
+		return nil, fmt.Errorf("ast.EncodeJSON: %s must be a node, got nil", path)
+	}
+	path = fmt.Sprintf("%s(%s)", path, nodeTypeName(node))
+
+	obj := map[string]interface{}{
+		"type": nodeTypeName(node),
+	}
+
+	var err error
+	set := func(key string, child Node) {
+		if err != nil {
+			return
+		}
+		obj[key], err = encodeNode(child, path+"."+key)
+	}
+
+	switch n := node.(type) {
+	// 文
+	case *Program:
+		obj["statements"], err = encodeStatementList(n.Statements, path+".statements")
+	case *LetStatement:
+		obj["token"] = encodeToken(n.Token)
+		set("name", n.Name)
+		set("value", n.Value)
+	case *ReturnStatement:
+		obj["token"] = encodeToken(n.Token)
+		if n.ReturnValue != nil {
+			set("returnValue", n.ReturnValue)
+		}
+	case *ExpressionStatement:
+		obj["token"] = encodeToken(n.Token)
+		set("expression", n.Expression)
+	case *BlockStatement:
+		obj["token"] = encodeToken(n.Token)
+		obj["rbrace"] = encodePosition(n.Rbrace)
+		obj["statements"], err = encodeStatementList(n.Statements, path+".statements")
+
+	// 式
+	case *Identifier:
+		obj["token"] = encodeToken(n.Token)
+		obj["value"] = n.Value
+	case *IntegerLiteral:
+		obj["token"] = encodeToken(n.Token)
+		obj["value"] = n.Value
+	case *FloatLiteral:
+		obj["token"] = encodeToken(n.Token)
+		obj["value"] = n.Value
+	case *StringLiteral:
+		obj["token"] = encodeToken(n.Token)
+		obj["value"] = n.Value
+	case *Boolean:
+		obj["token"] = encodeToken(n.Token)
+		obj["value"] = n.Value
+	case *ParenExpression:
+		obj["token"] = encodeToken(n.Token)
+		obj["rparen"] = encodePosition(n.Rparen)
+		set("expression", n.Expression)
+	case *PrefixExpression:
+		obj["token"] = encodeToken(n.Token)
+		obj["operator"] = n.Operator
+		set("right", n.Right)
+	case *InfixExpression:
+		obj["token"] = encodeToken(n.Token)
+		obj["operator"] = n.Operator
+		set("left", n.Left)
+		set("right", n.Right)
+	case *AssignExpression:
+		obj["token"] = encodeToken(n.Token)
+		obj["operator"] = n.Operator
+		set("name", n.Name)
+		set("value", n.Value)
+	case *IfExpression:
+		obj["token"] = encodeToken(n.Token)
+		set("condition", n.Condition)
+		set("consequence", n.Consequence)
+		if n.Alternative != nil {
+			set("alternative", n.Alternative)
+		}
+	case *FunctionLiteral:
+		obj["token"] = encodeToken(n.Token)
+		params := make([]interface{}, len(n.Parameters))
+		for i, p := range n.Parameters {
+			if params[i], err = encodeNode(p, fmt.Sprintf("%s.parameters[%d]", path, i)); err != nil {
+				return nil, err
+			}
+		}
+		obj["parameters"] = params
+		set("body", n.Body)
+	case *CallExpression:
+		obj["token"] = encodeToken(n.Token)
+		obj["rparen"] = encodePosition(n.Rparen)
+		set("function", n.Function)
+		if err == nil {
+			obj["arguments"], err = encodeExpressionList(n.Arguments, path+".arguments")
+		}
+	case *ArrayLiteral:
+		obj["token"] = encodeToken(n.Token)
+		obj["rbracket"] = encodePosition(n.Rbracket)
+		obj["elements"], err = encodeExpressionList(n.Elements, path+".elements")
+	case *IndexExpression:
+		obj["token"] = encodeToken(n.Token)
+		obj["rbracket"] = encodePosition(n.Rbracket)
+		set("left", n.Left)
+		set("index", n.Index)
+	case *SliceExpression:
+		obj["token"] = encodeToken(n.Token)
+		obj["rbracket"] = encodePosition(n.Rbracket)
+		set("left", n.Left)
+		if n.Low != nil {
+			set("low", n.Low)
+		}
+		if n.High != nil {
+			set("high", n.High)
+		}
+	case *HashLiteral:
+		obj["token"] = encodeToken(n.Token)
+		obj["rbrace"] = encodePosition(n.Rbrace)
+		pairs := make([]interface{}, len(n.Pairs))
+		for i, pair := range n.Pairs {
+			field := fmt.Sprintf("%s.pairs[%d]", path, i)
+			key, err := encodeNode(pair.Key, field+".key")
+			if err != nil {
+				return nil, err
+			}
+			value, err := encodeNode(pair.Value, field+".value")
+			if err != nil {
+				return nil, err
+			}
+			pairs[i] = map[string]interface{}{"key": key, "value": value}
+		}
+		obj["pairs"] = pairs
+
+	// 構文エラー
+	case *BadStatement:
+		obj["token"] = encodeToken(n.Token)
+		obj["to"] = encodePosition(n.To)
+	case *BadExpression:
+		obj["token"] = encodeToken(n.Token)
+		obj["to"] = encodePosition(n.To)
+
+	default:
+		return nil, fmt.Errorf("ast.EncodeJSON: %s: unexpected node type %T", path, n)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 子ノードがnilでないことを確かめてから範囲を求める(Pos()/End()は子ノードを辿る)
+	obj["pos"] = encodePosition(node.Pos())
+	obj["end"] = encodePosition(node.End())
+
+	return obj, nil
+}
+
+func encodeStatementList(list []Statement, path string) ([]interface{}, error) {
+	result := make([]interface{}, len(list))
+	for i, s := range list {
+		obj, err := encodeNode(s, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return nil, err
+		}
+		result[i] = obj
+	}
+	return result, nil
+}
+
+func encodeExpressionList(list []Expression, path string) ([]interface{}, error) {
+	result := make([]interface{}, len(list))
+	for i, e := range list {
+		obj, err := encodeNode(e, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return nil, err
+		}
+		result[i] = obj
+	}
+	return result, nil
+}
+
+// nodeTypeName "type" に出力する型名(パッケージ名とポインタを除いたもの)
+func nodeTypeName(node Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+// jsonObject デコード中のノード
+// フィールドごとに必要な型でUnmarshalする
+type jsonObject struct {
+	typ    string
+	fields map[string]json.RawMessage
+}
+
+// decodeNode JSONのオブジェクトからノードを組み立てる
+// nullの場合は(nil, nil)を返す
+// pathはエラーメッセージで使う、ルートからのフィールド名
+func decodeNode(data json.RawMessage, path string) (Node, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("ast.DecodeJSON: %s: %v", path, err)
+	}
+	if fields == nil {
+		return nil, nil
+	}
+
+	o := &jsonObject{fields: fields}
+	if err := o.field(path, "type", &o.typ); err != nil {
+		return nil, err
+	}
+	path = fmt.Sprintf("%s(%s)", path, o.typ)
+
+	var tok token.Token
+	if o.typ != "Program" {
+		var t jsonToken
+		if err := o.field(path, "token", &t); err != nil {
+			return nil, err
+		}
+		tok = decodeToken(t)
+	}
+
+	var err error
+	switch o.typ {
+	// 文
+	case "Program":
+		n := &Program{}
+		n.Statements, err = o.statements(path, "statements")
+		return n, err
+	case "LetStatement":
+		n := &LetStatement{Token: tok}
+		if n.Name, err = o.identifier(path, "name"); err != nil {
+			return nil, err
+		}
+		n.Value, err = o.expression(path, "value", false)
+		return n, err
+	case "ReturnStatement":
+		n := &ReturnStatement{Token: tok}
+		n.ReturnValue, err = o.expression(path, "returnValue", true)
+		return n, err
+	case "ExpressionStatement":
+		n := &ExpressionStatement{Token: tok}
+		n.Expression, err = o.expression(path, "expression", false)
+		return n, err
+	case "BlockStatement":
+		n := &BlockStatement{Token: tok}
+		if n.Rbrace, err = o.position(path, "rbrace"); err != nil {
+			return nil, err
+		}
+		n.Statements, err = o.statements(path, "statements")
+		return n, err
+
+	// 式
+	case "Identifier":
+		n := &Identifier{Token: tok}
+		return n, o.field(path, "value", &n.Value)
+	case "IntegerLiteral":
+		n := &IntegerLiteral{Token: tok}
+		return n, o.field(path, "value", &n.Value)
+	case "FloatLiteral":
+		n := &FloatLiteral{Token: tok}
+		return n, o.field(path, "value", &n.Value)
+	case "StringLiteral":
+		n := &StringLiteral{Token: tok}
+		return n, o.field(path, "value", &n.Value)
+	case "Boolean":
+		n := &Boolean{Token: tok}
+		return n, o.field(path, "value", &n.Value)
+	case "ParenExpression":
+		n := &ParenExpression{Token: tok}
+		if n.Rparen, err = o.position(path, "rparen"); err != nil {
+			return nil, err
+		}
+		n.Expression, err = o.expression(path, "expression", false)
+		return n, err
+	case "PrefixExpression":
+		n := &PrefixExpression{Token: tok}
+		if err := o.field(path, "operator", &n.Operator); err != nil {
+			return nil, err
+		}
+		n.Right, err = o.expression(path, "right", false)
+		return n, err
+	case "InfixExpression":
+		n := &InfixExpression{Token: tok}
+		if err := o.field(path, "operator", &n.Operator); err != nil {
+			return nil, err
+		}
+		if n.Left, err = o.expression(path, "left", false); err != nil {
+			return nil, err
+		}
+		n.Right, err = o.expression(path, "right", false)
+		return n, err
+	case "AssignExpression":
+		n := &AssignExpression{Token: tok}
+		if err := o.field(path, "operator", &n.Operator); err != nil {
+			return nil, err
+		}
+		if n.Name, err = o.identifier(path, "name"); err != nil {
+			return nil, err
+		}
+		n.Value, err = o.expression(path, "value", false)
+		return n, err
+	case "IfExpression":
+		n := &IfExpression{Token: tok}
+		if n.Condition, err = o.expression(path, "condition", false); err != nil {
+			return nil, err
+		}
+		if n.Consequence, err = o.block(path, "consequence", false); err != nil {
+			return nil, err
+		}
+		n.Alternative, err = o.block(path, "alternative", true)
+		return n, err
+	case "FunctionLiteral":
+		n := &FunctionLiteral{Token: tok}
+		var params []json.RawMessage
+		if err := o.field(path, "parameters", &params); err != nil {
+			return nil, err
+		}
+		n.Parameters = make([]*Identifier, len(params))
+		for i, data := range params {
+			field := fmt.Sprintf("%s.parameters[%d]", path, i)
+			if n.Parameters[i], err = decodeIdentifier(data, field); err != nil {
+				return nil, err
+			}
+		}
+		n.Body, err = o.block(path, "body", false)
+		return n, err
+	case "CallExpression":
+		n := &CallExpression{Token: tok}
+		if n.Rparen, err = o.position(path, "rparen"); err != nil {
+			return nil, err
+		}
+		if n.Function, err = o.expression(path, "function", false); err != nil {
+			return nil, err
+		}
+		n.Arguments, err = o.expressions(path, "arguments")
+		return n, err
+	case "ArrayLiteral":
+		n := &ArrayLiteral{Token: tok}
+		if n.Rbracket, err = o.position(path, "rbracket"); err != nil {
+			return nil, err
+		}
+		n.Elements, err = o.expressions(path, "elements")
+		return n, err
+	case "IndexExpression":
+		n := &IndexExpression{Token: tok}
+		if n.Rbracket, err = o.position(path, "rbracket"); err != nil {
+			return nil, err
+		}
+		if n.Left, err = o.expression(path, "left", false); err != nil {
+			return nil, err
+		}
+		n.Index, err = o.expression(path, "index", false)
+		return n, err
+	case "SliceExpression":
+		n := &SliceExpression{Token: tok}
+		if n.Rbracket, err = o.position(path, "rbracket"); err != nil {
+			return nil, err
+		}
+		if n.Left, err = o.expression(path, "left", false); err != nil {
+			return nil, err
+		}
+		if n.Low, err = o.expression(path, "low", true); err != nil {
+			return nil, err
+		}
+		n.High, err = o.expression(path, "high", true)
+		return n, err
+	case "HashLiteral":
+		n := &HashLiteral{Token: tok}
+		if n.Rbrace, err = o.position(path, "rbrace"); err != nil {
+			return nil, err
+		}
+		var pairs []map[string]json.RawMessage
+		if err := o.field(path, "pairs", &pairs); err != nil {
+			return nil, err
+		}
+		n.Pairs = make([]*HashPair, len(pairs))
+		for i, fields := range pairs {
+			pair := &jsonObject{typ: "HashPair", fields: fields}
+			field := fmt.Sprintf("%s.pairs[%d]", path, i)
+			key, err := pair.expression(field, "key", false)
+			if err != nil {
+				return nil, err
+			}
+			value, err := pair.expression(field, "value", false)
+			if err != nil {
+				return nil, err
+			}
+			n.Pairs[i] = &HashPair{Key: key, Value: value}
+		}
+		return n, nil
+
+	// 構文エラー
+	case "BadStatement":
+		n := &BadStatement{Token: tok}
+		n.To, err = o.position(path, "to")
+		return n, err
+	case "BadExpression":
+		n := &BadExpression{Token: tok}
+		n.To, err = o.position(path, "to")
+		return n, err
+	}
+
+	return nil, fmt.Errorf("ast.DecodeJSON: %s: unknown node type %q", path, o.typ)
+}
+
+// field 必須のフィールドをvに読み込む
+func (o *jsonObject) field(path, name string, v interface{}) error {
+	data, ok := o.fields[name]
+	if !ok {
+		return fmt.Errorf("ast.DecodeJSON: %s: missing field %q", path, name)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("ast.DecodeJSON: %s.%s: %v", path, name, err)
+	}
+	return nil
+}
+
+func (o *jsonObject) position(path, name string) (token.Position, error) {
+	var pos jsonPosition
+	if err := o.field(path, name, &pos); err != nil {
+		return token.Position{}, err
+	}
+	return decodePosition(pos), nil
+}
+
+// child 子ノードを読み込む
+// optionalな子ノードはフィールドがないかnullならnilを返す
+func (o *jsonObject) child(path, name string, optional bool) (Node, error) {
+	data, ok := o.fields[name]
+	if !ok && !optional {
+		return nil, fmt.Errorf("ast.DecodeJSON: %s: missing field %q", path, name)
+	}
+	if !ok {
+		return nil, nil
+	}
+	node, err := decodeNode(data, path+"."+name)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil && !optional {
+		return nil, fmt.Errorf("ast.DecodeJSON: %s.%s must be a node, got null", path, name)
+	}
+	return node, nil
+}
+
+func (o *jsonObject) expression(path, name string, optional bool) (Expression, error) {
+	node, err := o.child(path, name, optional)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	return toExpression(node, path+"."+name)
+}
+
+func (o *jsonObject) identifier(path, name string) (*Identifier, error) {
+	data, ok := o.fields[name]
+	if !ok {
+		return nil, fmt.Errorf("ast.DecodeJSON: %s: missing field %q", path, name)
+	}
+	return decodeIdentifier(data, path+"."+name)
+}
+
+func (o *jsonObject) block(path, name string, optional bool) (*BlockStatement, error) {
+	node, err := o.child(path, name, optional)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		return nil, decodeKindError(path+"."+name, "an *ast.BlockStatement", node)
+	}
+	return block, nil
+}
+
+func (o *jsonObject) statements(path, name string) ([]Statement, error) {
+	var list []json.RawMessage
+	if err := o.field(path, name, &list); err != nil {
+		return nil, err
+	}
+	result := make([]Statement, len(list))
+	for i, data := range list {
+		field := fmt.Sprintf("%s.%s[%d]", path, name, i)
+		node, err := decodeNode(data, field)
+		if err != nil {
+			return nil, err
+		}
+		statement, ok := node.(Statement)
+		if !ok {
+			return nil, decodeKindError(field, "a Statement", node)
+		}
+		result[i] = statement
+	}
+	return result, nil
+}
+
+func (o *jsonObject) expressions(path, name string) ([]Expression, error) {
+	var list []json.RawMessage
+	if err := o.field(path, name, &list); err != nil {
+		return nil, err
+	}
+	result := make([]Expression, len(list))
+	for i, data := range list {
+		field := fmt.Sprintf("%s.%s[%d]", path, name, i)
+		node, err := decodeNode(data, field)
+		if err != nil {
+			return nil, err
+		}
+		if result[i], err = toExpression(node, field); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func decodeIdentifier(data json.RawMessage, path string) (*Identifier, error) {
+	node, err := decodeNode(data, path)
+	if err != nil {
+		return nil, err
+	}
+	ident, ok := node.(*Identifier)
+	if !ok {
+		return nil, decodeKindError(path, "an *ast.Identifier", node)
+	}
+	return ident, nil
+}
+
+func toExpression(node Node, path string) (Expression, error) {
+	expression, ok := node.(Expression)
+	if !ok {
+		return nil, decodeKindError(path, "an Expression", node)
+	}
+	return expression, nil
+}
+
+// decodeKindError フィールドに入れられない種類のノードがJSONに書かれていた
+func decodeKindError(path, want string, got Node) error {
+	if got == nil {
+		return fmt.Errorf("ast.DecodeJSON: %s must be %s, got null", path, want)
+	}
+	return fmt.Errorf("ast.DecodeJSON: %s must be %s, got %s", path, want, nodeTypeName(got))
+}
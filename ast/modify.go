@@ -0,0 +1,214 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ModifierFunc Modifyが各ノードを置き換えるために呼び出す関数
+// 置き換えない場合は受け取ったノードをそのまま返す
+type ModifierFunc func(Node) Node
+
+// Modify nodeを帰りがけ順(子ノードが先)に辿り、各ノードをmodifier(node)の戻り値で置き換える
+// 子ノードはその場で書き換えるので、元のASTも変更される
+// 戻り値はmodifier(node)で置き換えた後のnode
+//
+// 置き換え先に入れられない種類のノード(式の場所に文など)やnil(型付きのnilポインタも含む)が返された場合はエラーになる
+// エラーの場合、それまでに置き換えたノードは元に戻らない
+func Modify(node Node, modifier ModifierFunc) (Node, error) {
+	result, err := modify(node, modifier)
+	if err != nil {
+		return nil, err
+	}
+	if isNil(result) {
+		return nil, kindError("result", "a Node", result)
+	}
+	return result, nil
+}
+
+// modify Modifyの本体
+// 子ノードの置き換え結果の検査は呼び出し側(modifyExpressionなど)で行う
+func modify(node Node, modifier ModifierFunc) (Node, error) {
+	var err error
+
+	switch n := node.(type) {
+	// 文
+	case *Program:
+		err = modifyStatementList(n.Statements, modifier, "Program.Statements")
+	case *LetStatement:
+		if n.Name != nil {
+			if n.Name, err = modifyIdentifier(n.Name, modifier, "LetStatement.Name"); err != nil {
+				return nil, err
+			}
+		}
+		if n.Value != nil {
+			n.Value, err = modifyExpression(n.Value, modifier, "LetStatement.Value")
+		}
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			n.ReturnValue, err = modifyExpression(n.ReturnValue, modifier, "ReturnStatement.ReturnValue")
+		}
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			n.Expression, err = modifyExpression(n.Expression, modifier, "ExpressionStatement.Expression")
+		}
+	case *BlockStatement:
+		err = modifyStatementList(n.Statements, modifier, "BlockStatement.Statements")
+
+	// 式
+	case *ParenExpression:
+		n.Expression, err = modifyExpression(n.Expression, modifier, "ParenExpression.Expression")
+	case *PrefixExpression:
+		n.Right, err = modifyExpression(n.Right, modifier, "PrefixExpression.Right")
+	case *InfixExpression:
+		if n.Left, err = modifyExpression(n.Left, modifier, "InfixExpression.Left"); err != nil {
+			return nil, err
+		}
+		n.Right, err = modifyExpression(n.Right, modifier, "InfixExpression.Right")
+	case *AssignExpression:
+		if n.Name, err = modifyIdentifier(n.Name, modifier, "AssignExpression.Name"); err != nil {
+			return nil, err
+		}
+		n.Value, err = modifyExpression(n.Value, modifier, "AssignExpression.Value")
+	case *IfExpression:
+		if n.Condition, err = modifyExpression(n.Condition, modifier, "IfExpression.Condition"); err != nil {
+			return nil, err
+		}
+		if n.Consequence, err = modifyBlock(n.Consequence, modifier, "IfExpression.Consequence"); err != nil {
+			return nil, err
+		}
+		if n.Alternative != nil {
+			n.Alternative, err = modifyBlock(n.Alternative, modifier, "IfExpression.Alternative")
+		}
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			field := fmt.Sprintf("FunctionLiteral.Parameters[%d]", i)
+			if n.Parameters[i], err = modifyIdentifier(p, modifier, field); err != nil {
+				return nil, err
+			}
+		}
+		n.Body, err = modifyBlock(n.Body, modifier, "FunctionLiteral.Body")
+	case *CallExpression:
+		if n.Function, err = modifyExpression(n.Function, modifier, "CallExpression.Function"); err != nil {
+			return nil, err
+		}
+		err = modifyExpressionList(n.Arguments, modifier, "CallExpression.Arguments")
+	case *ArrayLiteral:
+		err = modifyExpressionList(n.Elements, modifier, "ArrayLiteral.Elements")
+	case *IndexExpression:
+		if n.Left, err = modifyExpression(n.Left, modifier, "IndexExpression.Left"); err != nil {
+			return nil, err
+		}
+		n.Index, err = modifyExpression(n.Index, modifier, "IndexExpression.Index")
+	case *SliceExpression:
+		if n.Left, err = modifyExpression(n.Left, modifier, "SliceExpression.Left"); err != nil {
+			return nil, err
+		}
+		if n.Low != nil {
+			if n.Low, err = modifyExpression(n.Low, modifier, "SliceExpression.Low"); err != nil {
+				return nil, err
+			}
+		}
+		if n.High != nil {
+			n.High, err = modifyExpression(n.High, modifier, "SliceExpression.High")
+		}
+	case *HashLiteral:
+		for i, pair := range n.Pairs {
+			if pair.Key, err = modifyExpression(pair.Key, modifier, fmt.Sprintf("HashLiteral.Pairs[%d].Key", i)); err != nil {
+				return nil, err
+			}
+			if pair.Value, err = modifyExpression(pair.Value, modifier, fmt.Sprintf("HashLiteral.Pairs[%d].Value", i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return modifier(node), nil
+}
+
+// isNil nilか、nilポインタを入れたインターフェイス((*IntegerLiteral)(nil)など)か
+// 型付きのnilは == nil では見つけられず、後でString()などを呼んだ時にパニックになる
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// kindError 置き換え先に入れられないノードが返された
+func kindError(field, want string, got Node) error {
+	if got == nil {
+		return fmt.Errorf("ast.Modify: %s must be %s, got nil", field, want)
+	}
+	if isNil(got) {
+		return fmt.Errorf("ast.Modify: %s must be %s, got nil %T", field, want, got)
+	}
+	return fmt.Errorf("ast.Modify: %s must be %s, got %T", field, want, got)
+}
+
+// modifyExpression 子ノードの式を置き換える
+// エラーの場合は元の式を返すので、置き換え先には元のノードが残る
+func modifyExpression(e Expression, modifier ModifierFunc, field string) (Expression, error) {
+	node, err := modify(e, modifier)
+	if err != nil {
+		return e, err
+	}
+	expression, ok := node.(Expression)
+	if !ok || isNil(expression) {
+		return e, kindError(field, "an Expression", node)
+	}
+	return expression, nil
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc, field string) (*Identifier, error) {
+	node, err := modify(ident, modifier)
+	if err != nil {
+		return ident, err
+	}
+	result, ok := node.(*Identifier)
+	if !ok || isNil(result) {
+		return ident, kindError(field, "an *ast.Identifier", node)
+	}
+	return result, nil
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc, field string) (*BlockStatement, error) {
+	node, err := modify(block, modifier)
+	if err != nil {
+		return block, err
+	}
+	result, ok := node.(*BlockStatement)
+	if !ok || isNil(result) {
+		return block, kindError(field, "an *ast.BlockStatement", node)
+	}
+	return result, nil
+}
+
+func modifyStatementList(list []Statement, modifier ModifierFunc, field string) error {
+	for i, s := range list {
+		node, err := modify(s, modifier)
+		if err != nil {
+			return err
+		}
+		statement, ok := node.(Statement)
+		if !ok || isNil(statement) {
+			return kindError(fmt.Sprintf("%s[%d]", field, i), "a Statement", node)
+		}
+		list[i] = statement
+	}
+	return nil
+}
+
+func modifyExpressionList(list []Expression, modifier ModifierFunc, field string) error {
+	for i, e := range list {
+		var err error
+		if list[i], err = modifyExpression(e, modifier, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
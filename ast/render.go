@@ -0,0 +1,112 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// nodeLabel 描画するノードの名前
+// ノードの種類とTokenLiteralを並べる(Programはトークンを持たないので種類だけ)
+func nodeLabel(node Node) string {
+	if _, ok := node.(*Program); ok {
+		return "Program"
+	}
+	return fmt.Sprintf("%s %q", nodeTypeName(node), node.TokenLiteral())
+}
+
+// children nodeの直接の子ノード(Walkが辿る順)
+func children(node Node) []Node {
+	var list []Node
+	Inspect(node, func(n Node) bool {
+		if n == node {
+			return true
+		}
+		if n != nil {
+			list = append(list, n)
+		}
+		return false
+	})
+	return list
+}
+
+// WriteTree nodeを根とする木を、子ノードを字下げしたASCIIの図にしてwに書き出す
+// 子ノードは左から順(中置演算子なら左辺、右辺の順)に並ぶ
+//
+//	Program
+//	`-- ExpressionStatement "1"
+//	    `-- InfixExpression "+"
+//	        |-- IntegerLiteral "1"
+//	        `-- InfixExpression "*"
+//	            |-- IntegerLiteral "2"
+//	            `-- IntegerLiteral "3"
+func WriteTree(w io.Writer, node Node) error {
+	if _, err := fmt.Fprintln(w, nodeLabel(node)); err != nil {
+		return err
+	}
+	return writeSubtrees(w, node, "")
+}
+
+// writeSubtrees nodeの子ノードを書き出す
+// prefixは親までの枝の続き("|   " か "    " の繰り返し)
+func writeSubtrees(w io.Writer, node Node, prefix string) error {
+	list := children(node)
+	for i, child := range list {
+		branch, next := "|-- ", "|   "
+		if i == len(list)-1 {
+			branch, next = "`-- ", "    "
+		}
+		if _, err := fmt.Fprintf(w, "%s%s%s\n", prefix, branch, nodeLabel(child)); err != nil {
+			return err
+		}
+		if err := writeSubtrees(w, child, prefix+next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dotEscaper DOTの文字列("..."の中)で意味を持つ文字をエスケープする
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// WriteDOT nodeを根とする木をGraphvizのDOT形式でwに書き出す
+// 子ノードは左から順に並ぶ(ordering=out)ので、演算子の優先順位が木の形で分かる
+// 画像にするには dot -Tpng などに渡す
+//
+//	digraph AST {
+//		ordering=out;
+//		node [shape=box, fontname="monospace"];
+//		n0 [label="Program"];
+//		n1 [label="ExpressionStatement \"1\""];
+//		n0 -> n1;
+//		...
+//	}
+func WriteDOT(w io.Writer, node Node) error {
+	var b strings.Builder
+	b.WriteString("digraph AST {\n")
+	b.WriteString("\tordering=out;\n")
+	b.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+
+	// 行きがけ順に番号を振り、親の番号をスタックで覚えておく
+	var parents []int
+	id := 0
+	Inspect(node, func(n Node) bool {
+		// 子ノードを訪れ終わった
+		if n == nil {
+			parents = parents[:len(parents)-1]
+			return false
+		}
+
+		fmt.Fprintf(&b, "\tn%d [label=\"%s\"];\n", id, dotEscaper.Replace(nodeLabel(n)))
+		if len(parents) > 0 {
+			fmt.Fprintf(&b, "\tn%d -> n%d;\n", parents[len(parents)-1], id)
+		}
+		parents = append(parents, id)
+		id++
+		return true
+	})
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
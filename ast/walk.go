@@ -0,0 +1,124 @@
+package ast
+
+import "fmt"
+
+// Visitor Walkがノードを訪れるたびにVisitを呼び出す
+// Visitが返したVisitor wがnilでなければ、nodeの子ノードをそれぞれwで訪れた後に
+// w.Visit(nil)を呼び出す(子ノードを訪れ終わったことを知らせる帰りがけのフック)
+// nilを返すとnodeの子ノードは訪れない
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk nodeから深さ優先でASTを辿る
+// v.Visit(node)を呼び出し、その戻り値のVisitorで子ノードを左から順に辿る
+// nilの子ノード(省略されたelseやスライスの範囲、手で組み立てた不完全なASTなど)は訪れない
+// (*Identifier)(nil)のような型付きのnilも同じで、Visit(nil)は子ノードを訪れ終わった知らせにだけ使う
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// 文
+	case *Program:
+		walkStatementList(v, n.Statements)
+	case *LetStatement:
+		walkChild(v, n.Name)
+		walkChild(v, n.Value)
+	case *ReturnStatement:
+		walkChild(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walkChild(v, n.Expression)
+	case *BlockStatement:
+		walkStatementList(v, n.Statements)
+
+	// 式
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean:
+		// 子ノードはない
+	case *ParenExpression:
+		walkChild(v, n.Expression)
+	case *PrefixExpression:
+		walkChild(v, n.Right)
+	case *InfixExpression:
+		walkChild(v, n.Left)
+		walkChild(v, n.Right)
+	case *AssignExpression:
+		walkChild(v, n.Name)
+		walkChild(v, n.Value)
+	case *IfExpression:
+		walkChild(v, n.Condition)
+		walkChild(v, n.Consequence)
+		walkChild(v, n.Alternative)
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			walkChild(v, p)
+		}
+		walkChild(v, n.Body)
+	case *CallExpression:
+		walkChild(v, n.Function)
+		walkExpressionList(v, n.Arguments)
+	case *ArrayLiteral:
+		walkExpressionList(v, n.Elements)
+	case *IndexExpression:
+		walkChild(v, n.Left)
+		walkChild(v, n.Index)
+	case *SliceExpression:
+		walkChild(v, n.Left)
+		walkChild(v, n.Low)
+		walkChild(v, n.High)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			if pair == nil {
+				continue
+			}
+			walkChild(v, pair.Key)
+			walkChild(v, pair.Value)
+		}
+
+	// 構文エラー
+	case *BadStatement, *BadExpression:
+		// 子ノードはない
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// walkChild 子ノードがnilでなければ辿る
+func walkChild(v Visitor, node Node) {
+	if !isNil(node) {
+		Walk(v, node)
+	}
+}
+
+func walkStatementList(v Visitor, list []Statement) {
+	for _, s := range list {
+		walkChild(v, s)
+	}
+}
+
+func walkExpressionList(v Visitor, list []Expression) {
+	for _, e := range list {
+		walkChild(v, e)
+	}
+}
+
+// inspector 関数をVisitorとして使うためのアダプタ
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect nodeから深さ優先でASTを辿り、各ノードでf(node)を呼び出す
+// fがfalseを返すとそのノードの子ノードは訪れない
+// trueを返した場合は、子ノードを訪れた後にf(nil)が呼び出される
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
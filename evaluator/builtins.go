@@ -0,0 +1,149 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/koolii/go-monkey/object"
+)
+
+// builtins 組み込み関数の登録先
+// 識別子は環境で見つからなかった場合にここから探すので、同じ名前をletで束縛すれば上書きできる
+var builtins = map[string]*object.Builtin{}
+
+func init() {
+	RegisterBuiltin("len", builtinLen)
+	RegisterBuiltin("puts", builtinPuts)
+	RegisterBuiltin("first", builtinFirst)
+	RegisterBuiltin("last", builtinLast)
+	RegisterBuiltin("rest", builtinRest)
+	RegisterBuiltin("push", builtinPush)
+}
+
+// RegisterBuiltin Goのコードから組み込み関数を追加する
+// 同じ名前の組み込み関数があれば置き換える
+// 登録先はパッケージ全体で共有するmapで排他制御していないので、
+// initなど評価を始める前に呼び出すこと(評価中の他のgoroutineと同時に呼び出してはいけない)
+func RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	builtins[name] = &object.Builtin{Name: name, Fn: fn}
+}
+
+// BuiltinNames 登録されている組み込み関数の名前(辞書順)
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// builtinError 組み込み関数のエラー
+// 位置はapplyFunctionで呼び出し式の位置が設定される
+func builtinError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func checkArgCount(name string, args []object.Object, want int) *object.Error {
+	if len(args) != want {
+		return builtinError("wrong number of arguments to `%s`: want=%d, got=%d", name, want, len(args))
+	}
+	return nil
+}
+
+// arrayArg 配列を1つだけ受け取る組み込み関数の引数を検査する
+func arrayArg(name string, args []object.Object) (*object.Array, *object.Error) {
+	if err := checkArgCount(name, args, 1); err != nil {
+		return nil, err
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, builtinError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+	return array, nil
+}
+
+// len(x) 文字列の文字数、配列の要素数、ハッシュの組の数
+func builtinLen(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgCount("len", args, 1); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(len(arg.Pairs))}
+	default:
+		return builtinError("argument to `len` not supported, got %s", args[0].Type())
+	}
+}
+
+// puts(args...) 引数を1行ずつ環境の出力先(env.Output())に出力する
+func builtinPuts(env *object.Environment, args ...object.Object) object.Object {
+	out := env.Output()
+	for _, arg := range args {
+		fmt.Fprintln(out, arg.Inspect())
+	}
+	return NULL
+}
+
+// first(array) 最初の要素(空の配列ならnull)
+func builtinFirst(env *object.Environment, args ...object.Object) object.Object {
+	array, err := arrayArg("first", args)
+	if err != nil {
+		return err
+	}
+	if len(array.Elements) == 0 {
+		return NULL
+	}
+	return array.Elements[0]
+}
+
+// last(array) 最後の要素(空の配列ならnull)
+func builtinLast(env *object.Environment, args ...object.Object) object.Object {
+	array, err := arrayArg("last", args)
+	if err != nil {
+		return err
+	}
+	if len(array.Elements) == 0 {
+		return NULL
+	}
+	return array.Elements[len(array.Elements)-1]
+}
+
+// rest(array) 最初の要素を除いた新しい配列(空の配列ならnull)
+func builtinRest(env *object.Environment, args ...object.Object) object.Object {
+	array, err := arrayArg("rest", args)
+	if err != nil {
+		return err
+	}
+	length := len(array.Elements)
+	if length == 0 {
+		return NULL
+	}
+
+	elements := make([]object.Object, length-1)
+	copy(elements, array.Elements[1:length])
+	return &object.Array{Elements: elements}
+}
+
+// push(array, value) 末尾にvalueを追加した新しい配列(元の配列は変更しない)
+func builtinPush(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArgCount("push", args, 2); err != nil {
+		return err
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return builtinError("argument to `push` must be ARRAY, got %s", args[0].Type())
+	}
+
+	length := len(array.Elements)
+	elements := make([]object.Object, length+1)
+	copy(elements, array.Elements)
+	elements[length] = args[1]
+	return &object.Array{Elements: elements}
+}
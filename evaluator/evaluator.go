@@ -0,0 +1,647 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/koolii/go-monkey/ast"
+	"github.com/koolii/go-monkey/object"
+	"github.com/koolii/go-monkey/token"
+)
+
+// true/false/nullは毎回生成せずに同じインスタンスを参照する
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+)
+
+// Eval ASTのノードを再帰的に評価する(tree-walking interpreter)
+// 実行時エラーはpanicではなく*object.Errorとして返す
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+
+	// 文
+	case *ast.Program:
+		return evalProgram(node, env)
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+	case *ast.LetStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		env.Set(node.Name.Value, val)
+	case *ast.ReturnStatement:
+		val := Eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+
+	// 式
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.ParenExpression:
+		return Eval(node.Expression, env)
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Token.Pos, node.Operator, right)
+	case *ast.InfixExpression:
+		// && と || は右辺を評価する前に結果が決まることがある(短絡評価)
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalInfixExpression(node.Token.Pos, node.Operator, left, right)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return applyFunction(node.Token.Pos, function, args, env)
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(node.Token.Pos, left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
+	// 構文エラーから回復した部分的なASTは評価できない
+	case *ast.BadStatement:
+		return newError(node.Token.Pos, "cannot evaluate bad statement")
+	case *ast.BadExpression:
+		return newError(node.Token.Pos, "cannot evaluate bad expression")
+	}
+
+	return nil
+}
+
+// evalProgram 文を順に評価し、最後の文の値を返す
+// return文かエラーに達した時点で評価を止める
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range program.Statements {
+		result = Eval(statement, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+// evalBlockStatement evalProgramと違いReturnValueをアンラップしない
+// 入れ子のブロックの中のreturnで外側の関数全体の評価を止めるため
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range block.Statements {
+		result = Eval(statement, env)
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+// evalIdentifier 環境で見つからなければ組み込み関数から探す
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+	return newError(node.Token.Pos, "identifier not found: %s", node.Value)
+}
+
+func evalPrefixExpression(pos token.Position, operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(pos, right)
+	default:
+		return newError(pos, "unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+// !はnullとfalseだけをtrueにする
+func evalBangOperatorExpression(right object.Object) object.Object {
+	return nativeBoolToBooleanObject(!isTruthy(right))
+}
+
+func evalMinusPrefixOperatorExpression(pos token.Position, right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(pos, "unknown operator: -%s", right.Type())
+	}
+}
+
+func evalInfixExpression(pos token.Position, operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(pos, operator, left, right)
+	case isNumber(left) && isNumber(right):
+		// 整数と小数が混ざる場合は小数として計算する
+		return evalFloatInfixExpression(pos, operator, toFloat(left), toFloat(right))
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(pos, operator, left, right)
+	case operator == "==":
+		// 真偽値とnullは同じインスタンスなのでポインタで比較できる
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError(pos, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newError(pos, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(pos token.Position, operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return &object.Integer{Value: leftVal + rightVal}
+	case "-":
+		return &object.Integer{Value: leftVal - rightVal}
+	case "*":
+		return &object.Integer{Value: leftVal * rightVal}
+	case "/", "%":
+		if rightVal == 0 {
+			return newError(pos, "division by zero: %d %s %d", leftVal, operator, rightVal)
+		}
+		if operator == "/" {
+			return &object.Integer{Value: leftVal / rightVal}
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		if rightVal < 0 {
+			return newError(pos, "negative exponent: %d ** %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: intPow(leftVal, rightVal)}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<", ">>":
+		if rightVal < 0 {
+			return newError(pos, "negative shift count: %d %s %d", leftVal, operator, rightVal)
+		}
+		if operator == "<<" {
+			return &object.Integer{Value: leftVal << uint64(rightVal)}
+		}
+		return &object.Integer{Value: leftVal >> uint64(rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(pos, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// intPow 二乗を繰り返してbase ** expを計算する(expは0以上)
+func intPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+func evalFloatInfixExpression(pos token.Position, operator string, leftVal, rightVal float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(pos, "unknown operator: %s %s %s", object.FLOAT_OBJ, operator, object.FLOAT_OBJ)
+	}
+}
+
+func evalStringInfixExpression(pos token.Position, operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(pos, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalLogicalExpression && と || を短絡評価する
+// 左辺だけで結果が決まる場合は右辺を評価しない
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+// evalAssignExpression x += 1 を x = x + 1 として評価し、束縛されているスコープで更新する
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	current, ok := env.Get(node.Name.Value)
+	if !ok {
+		return newError(node.Name.Token.Pos, "identifier not found: %s", node.Name.Value)
+	}
+
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	// "+=" => "+"
+	operator := node.Operator[:len(node.Operator)-1]
+	result := evalInfixExpression(node.Token.Pos, operator, current, value)
+	if isError(result) {
+		return result
+	}
+
+	env.Assign(node.Name.Value, result)
+	return result
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	var result object.Object
+	if isTruthy(condition) {
+		result = Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		result = Eval(ie.Alternative, env)
+	}
+
+	// elseがない場合や、ブロックが値を生成しない場合はnull
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// evalIndexExpression 左辺の型に応じて配列かハッシュの添字アクセスを評価する
+func evalIndexExpression(pos token.Position, left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.ARRAY_OBJ:
+		return newError(pos, "array index must be INTEGER, got %s", index.Type())
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(pos, left, index)
+	default:
+		return newError(pos, "index operator not supported: %s", left.Type())
+	}
+}
+
+// evalArrayIndexExpression 負の添字は末尾から数える(-1は最後の要素)
+// 範囲外の場合はNULLを返す
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx := index.(*object.Integer).Value
+	length := int64(len(elements))
+
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return NULL
+	}
+
+	return elements[idx]
+}
+
+// evalHashIndexExpression キーがなければNULLを返す
+func evalHashIndexExpression(pos token.Position, hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError(pos, "unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hash.(*object.Hash).Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+// evalSliceExpression arr[low:high] はlowからhigh-1までの要素を持つ新しい配列を返す
+// 省略したlowは0、highは配列の長さになり、負の値は末尾から数える
+// 範囲外の値は配列の範囲に切り詰める
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	array, ok := left.(*object.Array)
+	if !ok {
+		return newError(node.Token.Pos, "slice operator not supported: %s", left.Type())
+	}
+	length := int64(len(array.Elements))
+
+	low, err := evalSliceBound(node.Token.Pos, node.Low, 0, length, env)
+	if err != nil {
+		return err
+	}
+	high, err := evalSliceBound(node.Token.Pos, node.High, length, length, env)
+	if err != nil {
+		return err
+	}
+
+	if low > high {
+		low = high
+	}
+	elements := make([]object.Object, high-low)
+	copy(elements, array.Elements[low:high])
+
+	return &object.Array{Elements: elements}
+}
+
+// evalSliceBound スライスの範囲を評価して 0 から length の間に収める
+// expが省略されている場合はdefaultValueを返す
+func evalSliceBound(pos token.Position, exp ast.Expression, defaultValue, length int64, env *object.Environment) (int64, object.Object) {
+	if exp == nil {
+		return defaultValue, nil
+	}
+
+	obj := Eval(exp, env)
+	if isError(obj) {
+		return 0, obj
+	}
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, newError(pos, "slice bounds must be INTEGER, got %s", obj.Type())
+	}
+
+	bound := integer.Value
+	if bound < 0 {
+		bound += length
+	}
+	if bound < 0 {
+		return 0, nil
+	}
+	if bound > length {
+		return length, nil
+	}
+	return bound, nil
+}
+
+// evalHashLiteral キーと値を書かれた順に評価する
+// 同じキーが複数あれば後のものが優先される
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError(pair.Key.Pos(), "unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Hash{Pairs: pairs}
+}
+
+// evalExpressions 引数を左から順に評価する
+// エラーが発生した場合はそのエラーだけを含むスライスを返す
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+func applyFunction(pos token.Position, fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		result := builtin.Fn(env, args...)
+		// 組み込み関数のエラーは呼び出し式の位置で報告する
+		if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+			err.Pos = pos
+		}
+		return result
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError(pos, "not a function: %s", fn.Type())
+	}
+
+	if len(args) != len(function.Parameters) {
+		return newError(pos, "wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+	}
+
+	extendedEnv := extendFunctionEnv(function, args)
+	evaluated := Eval(function.Body, extendedEnv)
+	return unwrapReturnValue(evaluated)
+}
+
+// extendFunctionEnv 関数が定義された環境を外側にして、仮引数に引数を束縛する
+func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+
+	for paramIdx, param := range fn.Parameters {
+		env.Set(param.Value, args[paramIdx])
+	}
+
+	return env
+}
+
+// unwrapReturnValue 関数の中のreturnが呼び出し元の評価まで止めないようにアンラップする
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	if obj == nil {
+		// 空の関数本体やlet文だけの本体
+		return NULL
+	}
+	return obj
+}
+
+// isTruthy nullとfalse以外はすべて真
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+func newError(pos token.Position, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Pos: pos}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+	return false
+}
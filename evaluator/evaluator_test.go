@@ -0,0 +1,714 @@
+package evaluator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/koolii/go-monkey/lexer"
+	"github.com/koolii/go-monkey/object"
+	"github.com/koolii/go-monkey/parser"
+)
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return Eval(program, env)
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"0xFF", 255},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"5 ** 0", 1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1e3", 1000},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"7.5 % 2", 1.5},
+		{"2 ** 0.5 ** 2", 1.189207115002721},
+		{"4.0 ** -1", 0.25},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalStringExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"こんにちは\n"`, "こんにちは\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. got=%q, want=%q", str.Value, tt.expected)
+		}
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 <= 1", true},
+		{"2 >= 3", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"1 == 1.0", true},
+		{"0.5 < 1", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" < "b"`, true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		// 短絡評価されるので未定義の識別子は評価されない
+		{"false && undefined", false},
+		{"true || undefined", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (true) { let x = 1; }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`
+if (10 > 1) {
+  if (10 > 1) {
+    return 10;
+  }
+
+  return 1;
+}
+`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "1:3: type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "1:3: type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "1:1: unknown operator: -BOOLEAN"},
+		{"true + false;", "1:6: unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "1:9: unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "1:20: unknown operator: BOOLEAN + BOOLEAN"},
+		{`
+if (10 > 1) {
+  if (10 > 1) {
+    return true + false;
+  }
+
+  return 1;
+}
+`, "4:17: unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "1:1: identifier not found: foobar"},
+		{`"Hello" - "World"`, "1:9: unknown operator: STRING - STRING"},
+		{"1.5 & 1", "1:5: unknown operator: FLOAT & FLOAT"},
+		{"10 / 0", "1:4: division by zero: 10 / 0"},
+		{"10 % 0", "1:4: division by zero: 10 % 0"},
+		{"2 ** -1", "1:3: negative exponent: 2 ** -1"},
+		{"1 << -1", "1:3: negative shift count: 1 << -1"},
+		{"true && foobar", "1:9: identifier not found: foobar"},
+		{"let x = 1; x += true", "1:14: type mismatch: INTEGER + BOOLEAN"},
+		{"y += 1", "1:1: identifier not found: y"},
+		{"let f = 1; f(2)", "1:13: not a function: INTEGER"},
+		{"let f = fn(x) { x }; f(1, 2)", "1:23: wrong number of arguments: want=1, got=2"},
+		{"[1, 2][true]", "1:7: array index must be INTEGER, got BOOLEAN"},
+		{"1[0]", "1:2: index operator not supported: INTEGER"},
+		{"1[0:1]", "1:2: slice operator not supported: INTEGER"},
+		{"[1, 2][0:true]", "1:7: slice bounds must be INTEGER, got BOOLEAN"},
+		{"[1, 2][foo]", "1:8: identifier not found: foo"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, "1:19: unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "1:2: unusable as hash key: ARRAY"},
+		{`{1.5: 2}`, "1:2: unusable as hash key: FLOAT"},
+		{`{"a": 1}[1:2]`, "1:9: slice operator not supported: HASH"},
+		{"1 + * 2", "1:5: cannot evaluate bad expression"},
+		{"let = 1; 2", "1:1: cannot evaluate bad statement"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		actual := errObj.Pos.String() + ": " + errObj.Message
+		if actual != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, actual)
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a += 2; a;", 7},
+		{"let a = 5; a -= 2;", 3},
+		{"let a = 5; a *= 2; a *= 2; a", 20},
+		{"let a = 5; a /= 2; a", 2},
+		{"let a = 1; let b = 2; a += b += 3; a", 6},
+		// 外側のスコープの束縛を更新する
+		{"let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
+
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v", fn.Parameters)
+	}
+
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+
+	expectedBody := "(x + 2)"
+
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let f = fn() { return 1; 2 }; f() + 1", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = fn(x) {
+  fn(y) { x + y };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestRecursiveFunction(t *testing.T) {
+	input := `
+let fib = fn(n) {
+  if (n <= 1) { return n; }
+  fib(n - 1) + fib(n - 2)
+};
+fib(15);`
+
+	testIntegerObject(t, testEval(input), 610)
+}
+
+func TestEmptyFunctionReturnsNull(t *testing.T) {
+	testNullObject(t, testEval("fn() {}()"))
+	testNullObject(t, testEval("fn() { let x = 1; }()"))
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][3]", nil},
+		// 負の添字は末尾から数える
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][-4]", nil},
+		{"[[1, 2], [3, 4]][1][0]", 3},
+		{"[fn(x) { x * 2 }][0](21)", 42},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestArraySliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][1:]", "[2, 3, 4]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		// 範囲外は切り詰める
+		{"[1, 2, 3, 4][2:10]", "[3, 4]"},
+		{"[1, 2, 3, 4][-10:1]", "[1]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{"[][0:1]", "[]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q - wrong result. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+			continue
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		// 型が違えば別のキー
+		{`{1: 5}["1"]`, nil},
+		{`{1: 5}[true]`, nil},
+		// 同じキーは後の値で上書きされる
+		{`{"a": 1, "a": 2}["a"]`, 2},
+		{`let h = {"f": fn(x) { x * 2 }}; h["f"](21)`, 42},
+		{`{"a": [1, {"b": 3}]}["a"][1]["b"]`, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		// 文字数を数える
+		{`len("日本語")`, 3},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len(1)`, "1:4: argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "1:4: wrong number of arguments to `len`: want=1, got=2"},
+		{`len()`, "1:4: wrong number of arguments to `len`: want=1, got=0"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "1:6: argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "1:5: argument to `last` must be ARRAY, got INTEGER"},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([1])`, []int{}},
+		{`rest([])`, nil},
+		{`rest([1], [2])`, "1:5: wrong number of arguments to `rest`: want=1, got=2"},
+		{`push([], 1)`, []int{1}},
+		{`push([1, 2], 3)`, []int{1, 2, 3}},
+		{`push(1, 1)`, "1:5: argument to `push` must be ARRAY, got INTEGER"},
+		{`push([1])`, "1:5: wrong number of arguments to `push`: want=2, got=1"},
+		// 元の配列は変更しない
+		{`let a = [1]; push(a, 2); rest(a); a`, []int{1}},
+		// letで束縛した名前は組み込み関数より優先される
+		{`let len = fn(x) { 42 }; len("a")`, 42},
+		{`let f = len; f([1, 2])`, 2},
+		{`let map = fn(arr, f) {
+			let iter = fn(arr, acc) {
+				if (len(arr) == 0) { acc } else { iter(rest(arr), push(acc, f(first(arr)))) }
+			};
+			iter(arr, []);
+		};
+		map([1, 2, 3], fn(x) { x * 2 })`, []int{2, 4, 6}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Inspect() != "ERROR: "+expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Inspect())
+			}
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func testEvalWithOutput(input string, out *bytes.Buffer) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	env.SetOutput(out)
+	return Eval(program, env)
+}
+
+func TestPuts(t *testing.T) {
+	var out bytes.Buffer
+	evaluated := testEvalWithOutput(`puts("hello", 1, [true]); puts()`, &out)
+	testNullObject(t, evaluated)
+
+	if out.String() != "hello\n1\n[true]\n" {
+		t.Errorf("puts output wrong. got=%q", out.String())
+	}
+}
+
+// 出力先は環境ごとに持つので、別々の環境で評価したputsの出力は混ざらない
+func TestPutsOutputPerEnvironment(t *testing.T) {
+	var first, second bytes.Buffer
+	testEvalWithOutput(`let f = fn(x) { puts(x) }; f("one")`, &first)
+	testEvalWithOutput(`puts("two")`, &second)
+	testEvalWithOutput(`let g = fn() { fn() { puts("three") } }; g()()`, &first)
+
+	if first.String() != "one\nthree\n" {
+		t.Errorf("first output wrong. got=%q", first.String())
+	}
+	if second.String() != "two\n" {
+		t.Errorf("second output wrong. got=%q", second.String())
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	RegisterBuiltin("double", func(env *object.Environment, args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	defer delete(builtins, "double")
+
+	testIntegerObject(t, testEval("double(21)"), 42)
+
+	found := false
+	for _, name := range BuiltinNames() {
+		if name == "double" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("BuiltinNames() does not contain double. got=%q", BuiltinNames())
+	}
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
@@ -1,6 +1,11 @@
 package lexer
 
 import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/koolii/go-monkey/token"
 )
 
@@ -8,52 +13,134 @@ import (
 // position/readPositionは入力を「覗き見」して、
 // 現在の文字に続いて何が来るかを考慮するため
 // readPositionは常に入力における「次の」１を指し示す
-// positionは現在検査中のバイトchの位置を示す
+// positionは現在検査中の文字chの先頭バイトの位置を示す
+// 入力はUTF-8として1文字(rune)ずつ読み込む
 type Lexer struct {
 	input        string
 	position     int  // 入力における現在の位置(現在の文字を指し示す)
 	readPosition int  // これから読み込む位置(現在の文字の次)
-	ch           byte // 現在検査中の文字
+	ch           rune // 現在検査中の文字
+	invalid      bool // chが不正なUTF-8のバイトであるかどうか
+	line         int  // chの行番号(1始まり)
+	column       int  // chの列番号(1始まり、文字単位)
+
+	emitComments bool // コメントをCOMMENTトークンとして返すかどうか
+
+	errors []Error
+}
+
+// Option Lexerの振る舞いを変更する
+type Option func(*Lexer)
+
+// WithComments コメントを読み飛ばさずにCOMMENTトークンとして返す
+// フォーマッタやドキュメント生成でコメントを残したい場合に使う
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.emitComments = true
+	}
+}
+
+// Error 字句解析中に見つかったエラー
+// Incompleteは閉じられないまま入力が終わったことを表す(続きの入力があれば解消できる)
+type Error struct {
+	Pos        token.Position
+	Msg        string
+	Incomplete bool
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
 }
 
 // New is create Lexer pointer
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+func New(input string, opts ...Option) *Lexer {
+	l := &Lexer{input: input, line: 1}
+	for _, opt := range opts {
+		opt(l)
+	}
 	l.readChar()
 	return l
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+// Errors これまでに見つかった字句解析エラー
+// エラーとなった箇所はILLEGALトークンとして返される
+func (l *Lexer) Errors() []Error {
+	return l.errors
+}
+
+func (l *Lexer) error(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
+}
+
+// incompleteError 入力の終端に達したことによるエラー
+func (l *Lexer) incompleteError(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, Error{Pos: pos, Msg: fmt.Sprintf(format, a...), Incomplete: true})
+}
+
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// newTwoCharToken 現在の文字と次の文字からなるトークンを作る
+// 次の文字まで読み進めるので、NextToken最後のreadChar()で2文字分進んだことになる
+func (l *Lexer) newTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+// isLetter 識別子に使える文字
+// unicode.IsLetterと同じ判定なので日本語の識別子も使える
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	// 浮動点小数型は完全にサポートしていなかった
 	return '0' <= ch && ch <= '9'
 }
 
 // readChar 次の1文字を呼んでinput文字列の現在位置(postiion)を進める
-// Unicodeに対応していない
-// 対応する場合は l.chをbyteからruneに変更し、次の文字を読む処理を変更する必要がある
-// (※ 次の文字が複数のバイトから構成される可能性があるため l.input[l.readPosition]は使えない
+// 次の文字が複数のバイトから構成される可能性があるため、
+// utf8.DecodeRuneInStringでデコードしてその幅だけreadPositionを進める
 // ミュータブルにLexer内を移動させる
 func (l *Lexer) readChar() {
+	// 既に終端に達している場合は位置を進めない
+	if l.readPosition > 0 && l.position >= len(l.input) {
+		return
+	}
+
+	// 行・列の更新処理
+	// 直前の文字が改行であれば次の行の先頭に移る
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
+	// positionの更新処理
+	l.position = l.readPosition
+
 	// 終端チェック
 	if l.readPosition >= len(l.input) {
 		// ASCIIで言うところの "NUL"
 		l.ch = 0
-		// fmt.Printf("readChar(): EOF\n")
-	} else {
-		l.ch = l.input[l.readPosition]
-		// fmt.Printf("readChar(): %c\n", l.ch)
+		l.invalid = false
+		l.readPosition++
+		return
 	}
-	// positionの更新処理
-	l.position = l.readPosition
-	l.readPosition++
+
+	r, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	l.ch = r
+	// 幅1のRuneErrorはデコードできなかったバイト
+	l.invalid = r == utf8.RuneError && width == 1
+	l.readPosition += width
+}
+
+// curPosition 現在検査中の文字chの位置
+func (l *Lexer) curPosition() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.column}
 }
 
 // NextToken is increments Lexer
@@ -62,6 +149,25 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	// コメントは読み飛ばすか、COMMENTトークンとして返す
+	for l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+		start := l.curPosition()
+		tok = l.readComment()
+		tok.Pos, tok.End = start, l.curPosition()
+		if tok.Type == token.ILLEGAL || l.emitComments {
+			return tok
+		}
+		l.skipWhitespace()
+	}
+
+	// トークンの開始位置を記録しておく
+	start := l.curPosition()
+
+	// 終端はchではなく位置で判断する(入力中のNUL文字は不正な文字として扱う)
+	if l.atEOF() {
+		return token.Token{Type: token.EOF, Literal: "", Pos: start, End: start}
+	}
+
 	// case: 0って数字の時はどうする?
 	switch l.ch {
 	// 1 char's tokens
@@ -96,24 +202,86 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		switch l.peekChar() {
+		case '*':
+			tok = l.newTwoCharToken(token.POWER)
+		case '=':
+			tok = l.newTwoCharToken(token.ASTERISK_ASSIGN)
+		default:
+			tok = newToken(token.ASTERISK, l.ch)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.LT_EQ)
+		case '<':
+			tok = l.newTwoCharToken(token.SHIFT_LEFT)
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.GT_EQ)
+		case '>':
+			tok = l.newTwoCharToken(token.SHIFT_RIGHT)
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.newTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.BIT_AND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.newTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.BIT_OR, l.ch)
+		}
+	case '^':
+		tok = newToken(token.BIT_XOR, l.ch)
+	case '"':
+		if str, ok := l.readString(); ok {
+			tok.Type, tok.Literal = token.STRING, str
+		} else {
+			// エラーの場合は読み込んだ範囲をそのままリテラルにする
+			tok.Type, tok.Literal = token.ILLEGAL, l.input[start.Offset:l.position]
+			if l.ch == '"' {
+				tok.Literal += `"`
+			}
+		}
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
-	case 0:
-		tok.Literal = ""
-		tok.Type = token.EOF
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
 	default:
 		// fmt.Println("This is default case")
 		if isLetter(l.ch) {
@@ -121,28 +289,37 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			// fmt.Printf("This is identifier: %s\n", tok.Literal)
 			// readIdentifier()でreadChar()を実行しているため、余分にreadChar()を実行させない
+			tok.Pos, tok.End = start, l.curPosition()
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Pos, tok.End = start, l.curPosition()
 			return tok
 		}
-		tok = newToken(token.ILLEGAL, l.ch)
+		// 不正なUTF-8はバイトをそのままリテラルにする
+		tok = token.Token{Type: token.ILLEGAL, Literal: l.input[l.position:l.readPosition]}
+		if l.invalid {
+			l.error(start, "invalid UTF-8 encoding %q", tok.Literal)
+		} else {
+			l.error(start, "unexpected character %q", l.ch)
+		}
 	}
 
 	l.readChar()
+	// 終了位置は最後の文字の直後
+	tok.Pos, tok.End = start, l.curPosition()
 	return tok
 }
 
 // peek = 覗く
 // 次の文字を覗くだけでインクリメントしない
 // 2文字トークンをチェックするために switch文内で != / ==をチェック
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return r
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -152,6 +329,41 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+// readComment // から行末まで、または /* から対応する */ までを読み込む
+// ブロックコメントは入れ子にできる
+// 読み込み後はコメントの直後の文字がchにセットされた状態になる
+func (l *Lexer) readComment() token.Token {
+	start := l.curPosition()
+
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && !l.atEOF() {
+			l.readChar()
+		}
+		return token.Token{Type: token.COMMENT, Literal: l.input[start.Offset:l.position]}
+	}
+
+	// 開始の /* を読み飛ばす
+	l.readChar()
+	l.readChar()
+	depth := 1
+	for depth > 0 {
+		switch {
+		case l.atEOF():
+			// エラーはコメントの開始位置で報告する
+			l.incompleteError(start, "unterminated block comment")
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[start.Offset:]}
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+		}
+		l.readChar()
+	}
+	return token.Token{Type: token.COMMENT, Literal: l.input[start.Offset:l.position]}
+}
+
 func (l *Lexer) readIdentifier() string {
 	// 読み込む初期位置を取得
 	position := l.position
@@ -163,13 +375,159 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber 数値リテラルを読み込む
+// 0x/0o/0bの接頭辞、桁区切りの_、小数点と指数を含むものはFLOATになる
+// 数字や英字が続く限りは読み込み、正しい形式かどうかは構文解析器が判定する
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	// 読み込む初期位置を取得
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	// 接頭辞付きの場合は小数点・指数を扱わない(0xのeは16進数の桁)
+	prefixed := false
+	if l.ch == '0' {
+		switch l.peekChar() {
+		case 'x', 'X', 'o', 'O', 'b', 'B':
+			prefixed = true
+		}
+	}
+
 	// 途切れるところまで読み込む(終端位置を取得)
-	for isDigit(l.ch) {
+	for {
+		switch {
+		case !prefixed && (l.ch == 'e' || l.ch == 'E'):
+			tokenType = token.FLOAT
+			if p := l.peekChar(); p == '+' || p == '-' {
+				l.readChar()
+			}
+			l.readChar()
+		case isDigit(l.ch) || isASCIILetter(l.ch) || l.ch == '_':
+			l.readChar()
+		case !prefixed && tokenType == token.INT && l.ch == '.' && isDigit(l.peekChar()):
+			tokenType = token.FLOAT
+			l.readChar()
+		default:
+			// 初期位置-終端位置までの文字列を取得
+			return tokenType, l.input[position:l.position]
+		}
+	}
+}
+
+func isASCIILetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
+}
+
+// readString 開始の " から終了の " までを読み込み、エスケープシーケンスを解釈した文字列を返す
+// 読み込み後は終了の " がchにセットされた状態になる
+// 閉じられていない文字列や不正なエスケープシーケンスはエラーを記録してfalseを返す
+func (l *Lexer) readString() (string, bool) {
+	start := l.curPosition()
+	var out strings.Builder
+	ok := true
+
+	for {
 		l.readChar()
+
+		switch {
+		case l.ch == '"':
+			return out.String(), ok
+		case l.atEOF():
+			l.incompleteError(start, "unterminated string literal")
+			return out.String(), false
+		case l.invalid:
+			l.error(l.curPosition(), "invalid UTF-8 encoding %q in string literal", l.input[l.position:l.readPosition])
+			ok = false
+		case l.ch == '\\':
+			r, valid := l.readEscape()
+			if !valid {
+				ok = false
+				continue
+			}
+			out.WriteRune(r)
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// readEscape \ に続くエスケープシーケンスを読み込む
+// 読み込み後はシーケンスの最後の文字がchにセットされた状態になる
+func (l *Lexer) readEscape() (rune, bool) {
+	pos := l.curPosition()
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'r':
+		return '\r', true
+	case '"':
+		return '"', true
+	case '\\':
+		return '\\', true
+	case 'u':
+		return l.readUnicodeEscape(pos)
+	}
+
+	if l.atEOF() {
+		// 閉じられていない文字列としてreadStringで報告する
+		return 0, false
+	}
+	l.error(pos, "unknown escape sequence \\%c", l.ch)
+	return 0, false
+}
+
+// readUnicodeEscape \u{XXXX} 形式のエスケープシーケンスを読み込む
+// 16進数は1-6桁で、サロゲートやU+10FFFFを超える値は使えない
+func (l *Lexer) readUnicodeEscape(pos token.Position) (rune, bool) {
+	if l.peekChar() != '{' {
+		l.error(pos, "invalid Unicode escape: expected \\u{...}")
+		return 0, false
+	}
+	l.readChar()
+
+	var value rune
+	digits := 0
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+		value = value*16 + hexValue(l.ch)
+		digits++
+		if digits > 6 {
+			break
+		}
+	}
+
+	if digits == 0 || digits > 6 || l.peekChar() != '}' {
+		l.error(pos, "invalid Unicode escape: expected 1 to 6 hex digits in \\u{...}")
+		return 0, false
+	}
+	l.readChar()
+
+	if value > unicode.MaxRune || 0xD800 <= value && value <= 0xDFFF {
+		l.error(pos, "invalid Unicode escape: U+%X is not a valid code point", value)
+		return 0, false
+	}
+	return value, true
+}
+
+// atEOF 入力の終端に達しているかどうか
+func (l *Lexer) atEOF() bool {
+	return l.position >= len(l.input)
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func hexValue(ch rune) rune {
+	switch {
+	case isDigit(ch):
+		return ch - '0'
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10
+	default:
+		return ch - 'A' + 10
 	}
-	// 初期位置-終端位置までの文字列を取得
-	return l.input[position:l.position]
 }
@@ -51,7 +51,7 @@ func TestNextTokenStep2(t *testing.T) {
 let add = fn(x, y) { x + y;
 };
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -146,3 +146,412 @@ if (5 < 10) {
 		t.Fatalf(errorState)
 	}
 }
+
+func TestNextTokenPosition(t *testing.T) {
+	input := `let x = 10;
+  x == 5
+`
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.SEMICOLON, token.Position{Offset: 10, Line: 1, Column: 11}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{token.IDENT, token.Position{Offset: 14, Line: 2, Column: 3}, token.Position{Offset: 15, Line: 2, Column: 4}},
+		{token.EQ, token.Position{Offset: 16, Line: 2, Column: 5}, token.Position{Offset: 18, Line: 2, Column: 7}},
+		{token.INT, token.Position{Offset: 19, Line: 2, Column: 8}, token.Position{Offset: 20, Line: 2, Column: 9}},
+		{token.EOF, token.Position{Offset: 21, Line: 3, Column: 1}, token.Position{Offset: 21, Line: 3, Column: 1}},
+		// EOFの後は位置が進まない
+		{token.EOF, token.Position{Offset: 21, Line: 3, Column: 1}, token.Position{Offset: 21, Line: 3, Column: 1}},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - pos wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestNextTokenUnicode(t *testing.T) {
+	input := `let 変数 = 5;
+let café_ü = 変数;`
+
+	tests := []TestCase{
+		{token.LET, "let"},
+		{token.IDENT, "変数"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENT, "café_ü"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "変数"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	errorState := spec(input, tests)
+	if errorState != "" {
+		t.Fatalf(errorState)
+	}
+}
+
+func TestNextTokenUnicodePosition(t *testing.T) {
+	// 列は文字単位、オフセットはバイト単位
+	input := "変数 = 1"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.IDENT, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 6, Line: 1, Column: 3}},
+		{token.ASSIGN, token.Position{Offset: 7, Line: 1, Column: 4}, token.Position{Offset: 8, Line: 1, Column: 5}},
+		{token.INT, token.Position{Offset: 9, Line: 1, Column: 6}, token.Position{Offset: 10, Line: 1, Column: 7}},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - pos wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestNextTokenInvalidUTF8(t *testing.T) {
+	input := "x = \xff;"
+
+	tests := []TestCase{
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.ILLEGAL, "\xff"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 lexer error. got=%d", len(errors))
+	}
+	expected := `1:5: invalid UTF-8 encoding "\xff"`
+	if errors[0].Error() != expected {
+		t.Errorf("error wrong. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestNextTokenNUL(t *testing.T) {
+	input := "let a = 1;\x00 let b = 2;"
+
+	tests := []TestCase{
+		{token.LET, "let"},
+		{token.IDENT, "a"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "\x00"},
+		{token.LET, "let"},
+		{token.IDENT, "b"},
+		{token.ASSIGN, "="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 lexer error. got=%d", len(errors))
+	}
+	expected := `1:11: unexpected character '\x00'`
+	if errors[0].Error() != expected {
+		t.Errorf("error wrong. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestNextTokenString(t *testing.T) {
+	input := `"foobar" "foo bar" "" "tab\there" "say \"hi\"" "back\\slash" "line\n" "\u{3042}\u{1F600}" "日本語"`
+
+	tests := []TestCase{
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.STRING, ""},
+		{token.STRING, "tab\there"},
+		{token.STRING, `say "hi"`},
+		{token.STRING, `back\slash`},
+		{token.STRING, "line\n"},
+		{token.STRING, "あ😀"},
+		{token.STRING, "日本語"},
+		{token.EOF, ""},
+	}
+
+	errorState := spec(input, tests)
+	if errorState != "" {
+		t.Fatalf(errorState)
+	}
+}
+
+func TestNextTokenStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+	}{
+		{`"abc`, `"abc`, "1:1: unterminated string literal"},
+		{`"abc\`, `"abc\`, "1:1: unterminated string literal"},
+		{`x + "a\qb"`, `"a\qb"`, `1:7: unknown escape sequence \q`},
+		{`"\u0041"`, `"\u0041"`, `1:2: invalid Unicode escape: expected \u{...}`},
+		{`"\u{}"`, `"\u{}"`, `1:2: invalid Unicode escape: expected 1 to 6 hex digits in \u{...}`},
+		{`"\u{1234567}"`, `"\u{1234567}"`, `1:2: invalid Unicode escape: expected 1 to 6 hex digits in \u{...}`},
+		{`"\u{D800}"`, `"\u{D800}"`, `1:2: invalid Unicode escape: U+D800 is not a valid code point`},
+		{`"\u{110000}"`, `"\u{110000}"`, `1:2: invalid Unicode escape: U+110000 is not a valid code point`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		var illegal token.Token
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if tok.Type == token.ILLEGAL {
+				illegal = tok
+			}
+		}
+
+		if illegal.Literal != tt.expectedLiteral {
+			t.Errorf("input %q - ILLEGAL literal wrong. expected=%q, got=%q", tt.input, tt.expectedLiteral, illegal.Literal)
+		}
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("input %q - expected 1 lexer error. got=%d", tt.input, len(errors))
+			continue
+		}
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("input %q - error wrong. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestNextTokenNumber(t *testing.T) {
+	input := `5 3.14 1e-9 2.5E+3 0xFF 0o755 0b1010 1_000_000 007 1.x 0xe+1 123abc`
+
+	tests := []TestCase{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.INT, "0xFF"},
+		{token.INT, "0o755"},
+		{token.INT, "0b1010"},
+		{token.INT, "1_000_000"},
+		{token.INT, "007"},
+		// 小数点の後に数字が続かなければ小数にはならない
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+		// 16進数のeは指数ではない
+		{token.INT, "0xe"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		// 形式の検査は構文解析器が行う
+		{token.INT, "123abc"},
+		{token.EOF, ""},
+	}
+
+	errorState := spec(input, tests)
+	if errorState != "" {
+		t.Fatalf(errorState)
+	}
+}
+
+func TestNextTokenComment(t *testing.T) {
+	input := `// line comment
+let x = 5; // trailing
+/* block
+   comment */ x /* nested /* block */ comment */ + 1
+/**/10 / 2`
+
+	tests := []TestCase{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	}
+
+	errorState := spec(input, tests)
+	if errorState != "" {
+		t.Fatalf(errorState)
+	}
+
+	// オプションを指定するとCOMMENTトークンとして返される
+	tests = []TestCase{
+		{token.COMMENT, "// line comment"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing"},
+		{token.COMMENT, "/* block\n   comment */"},
+		{token.IDENT, "x"},
+		{token.COMMENT, "/* nested /* block */ comment */"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.COMMENT, "/**/"},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	}
+
+	l := New(input, WithComments())
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenUnterminatedComment(t *testing.T) {
+	input := `x +
+  /* open /* nested */
+1`
+
+	l := New(input)
+	tests := []TestCase{
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.ILLEGAL, "/* open /* nested */\n1"},
+		{token.EOF, ""},
+	}
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 lexer error. got=%d", len(errors))
+	}
+	// 開始位置で報告される
+	expected := "2:3: unterminated block comment"
+	if errors[0].Error() != expected {
+		t.Errorf("error wrong. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestNextTokenOperators(t *testing.T) {
+	input := `<= >= && || % ** & | ^ << >> += -= *= /= < > * /`
+
+	tests := []TestCase{
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.AND, "&&"},
+		{token.OR, "||"},
+		{token.PERCENT, "%"},
+		{token.POWER, "**"},
+		{token.BIT_AND, "&"},
+		{token.BIT_OR, "|"},
+		{token.BIT_XOR, "^"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.MINUS_ASSIGN, "-="},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.SLASH_ASSIGN, "/="},
+		{token.LT, "<"},
+		{token.GT, ">"},
+		{token.ASTERISK, "*"},
+		{token.SLASH, "/"},
+		{token.EOF, ""},
+	}
+
+	errorState := spec(input, tests)
+	if errorState != "" {
+		t.Fatalf(errorState)
+	}
+}
+
+func TestNextTokenBrackets(t *testing.T) {
+	input := `[1, 2][0]; arr[1:-1]`
+
+	tests := []TestCase{
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "arr"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.RBRACKET, "]"},
+		{token.EOF, ""},
+	}
+
+	errorState := spec(input, tests)
+	if errorState != "" {
+		t.Fatalf(errorState)
+	}
+}
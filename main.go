@@ -1,20 +1,87 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/user"
 
+	"github.com/koolii/go-monkey/ast"
+	"github.com/koolii/go-monkey/lexer"
+	"github.com/koolii/go-monkey/parser"
 	"github.com/koolii/go-monkey/repl"
 )
 
 func main() {
+	astFormat := flag.String("ast", "", "print the AST of the file argument (or stdin) as `format` (tree, dot or json) instead of starting the REPL")
+	flag.Parse()
+
+	if *astFormat != "" {
+		os.Exit(printAST(*astFormat, flag.Arg(0)))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
+	fmt.Printf("Feel free to type in commands (:help for REPL commands)\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+// printAST ファイル(空の場合は標準入力)を構文解析して、ASTをformatの形式で標準出力に書き出す
+// 戻り値は終了コード
+//
+//	go run . -ast=dot example.monkey | dot -Tpng -o ast.png
+func printAST(format, filename string) int {
+	var write func(io.Writer, ast.Node) error
+	switch format {
+	case "tree":
+		write = ast.WriteTree
+	case "dot":
+		write = ast.WriteDOT
+	case "json":
+		write = func(w io.Writer, node ast.Node) error {
+			data, err := ast.EncodeJSON(node)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "%s\n", data)
+			return err
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown AST format: %s (want tree, dot or json)\n", format)
+		return 2
+	}
+
+	var input []byte
+	var err error
+	if filename == "" || filename == "-" {
+		filename = "<stdin>"
+		input, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		input, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(input)))
+	program := p.ParseProgram()
+	if errors := p.ParseErrors(); len(errors) != 0 {
+		for _, err := range errors {
+			fmt.Fprintf(os.Stderr, "%s:%s\n%s\n", filename, err, err.Snippet(string(input)))
+		}
+		return 1
+	}
+
+	if err := write(os.Stdout, program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
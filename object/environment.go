@@ -0,0 +1,89 @@
+package object
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+// Environment 識別子と値の束縛を管理する
+// outerは外側のスコープで、見つからない場合はそちらを探す
+type Environment struct {
+	store  map[string]Object
+	outer  *Environment
+	output io.Writer // putsなどの出力先(nilなら外側の環境の出力先)
+}
+
+// NewEnvironment is create Environment pointer
+func NewEnvironment() *Environment {
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: nil}
+}
+
+// NewEnclosedEnvironment 関数呼び出しのためにouterを拡張した環境を作る
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get 内側のスコープから順に名前を探す
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set 現在のスコープに名前を束縛する
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Assign 既に束縛されている名前の値を、束縛されているスコープで更新する
+// どのスコープにも束縛されていない場合はfalseを返す
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
+// SetOutput この環境と、この環境を外側に持つ環境(関数呼び出しなど)での出力先を設定する
+func (e *Environment) SetOutput(w io.Writer) {
+	e.output = w
+}
+
+// Output 内側のスコープから順に出力先を探す
+// どのスコープにも設定されていなければos.Stdout
+func (e *Environment) Output() io.Writer {
+	for env := e; env != nil; env = env.outer {
+		if env.output != nil {
+			return env.output
+		}
+	}
+	return os.Stdout
+}
+
+// Names 外側のスコープも含めて束縛されているすべての名前(辞書順)
+func (e *Environment) Names() []string {
+	seen := make(map[string]bool)
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,230 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/koolii/go-monkey/ast"
+	"github.com/koolii/go-monkey/token"
+)
+
+// ObjectType 評価結果の値の種類
+type ObjectType string
+
+const (
+	INTEGER_OBJ = "INTEGER"
+	FLOAT_OBJ   = "FLOAT"
+	BOOLEAN_OBJ = "BOOLEAN"
+	STRING_OBJ  = "STRING"
+	NULL_OBJ    = "NULL"
+
+	// RETURN_VALUE_OBJ return文の値をラップして、それ以降の文の評価を止めるために使う
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	// ERROR_OBJ 実行時エラー(Goのpanicにはしない)
+	ERROR_OBJ = "ERROR"
+
+	FUNCTION_OBJ = "FUNCTION"
+	ARRAY_OBJ    = "ARRAY"
+	HASH_OBJ     = "HASH"
+	BUILTIN_OBJ  = "BUILTIN"
+)
+
+// Object 評価した値はすべてObjectインターフェイスを実装する
+// Inspect()はREPLでの表示やデバッグ用
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+// Integer is for ast.IntegerLiteral
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// Float is for ast.FloatLiteral
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect 整数と区別できるように、小数点も指数もない場合は ".0" を付ける
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// Boolean is for ast.Boolean
+// true/falseはそれぞれ一つのインスタンスを使い回す
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+// String is for ast.StringLiteral
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// Null 値がないことを表す
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+// ReturnValue return文で返された値
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// Error 実行時エラー
+// Posはエラーの原因となったノードのソース上の位置
+type Error struct {
+	Message string
+	Pos     token.Position
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string {
+	if !e.Pos.IsValid() {
+		return "ERROR: " + e.Message
+	}
+	return fmt.Sprintf("ERROR: %s: %s", e.Pos, e.Message)
+}
+
+// Function 関数リテラルを評価した値
+// Envは関数が定義された環境で、クロージャとして呼び出し時の環境の外側になる
+type Function struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+// BuiltinFunction Goで実装された組み込み関数
+// envは呼び出した場所の環境で、出力先(env.Output())などを取り出すのに使う
+// エラーは*object.Errorとして返す(位置は呼び出し側で設定される)
+type BuiltinFunction func(env *Environment, args ...Object) Object
+
+// Builtin 組み込み関数の値
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function " + b.Name }
+
+// Array 配列リテラルを評価した値
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashKey ハッシュのキーとして比較するための値
+// 型が違えば値が同じでも別のキーになる(1と"1"やtrueは区別される)
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable ハッシュのキーとして使える値(Integer, Boolean, String)
+type Hashable interface {
+	HashKey() HashKey
+}
+
+// HashPair 元のキーのオブジェクトと値
+// Inspectでキーを表示するためにキーも保持する
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash ハッシュリテラルを評価した値
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+
+// Inspect mapの順序は不定なので、キーの表示順に並べて出力する
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+	sort.Strings(pairs)
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
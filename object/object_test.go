@@ -0,0 +1,152 @@
+package object
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/koolii/go-monkey/token"
+)
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{&Integer{Value: -5}, "-5"},
+		{&Float{Value: 3}, "3.0"},
+		{&Float{Value: 0.25}, "0.25"},
+		{&Float{Value: 1e21}, "1e+21"},
+		{&Boolean{Value: true}, "true"},
+		{&String{Value: "あ"}, "あ"},
+		{&Null{}, "null"},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}, "[1, a]"},
+		{&Array{}, "[]"},
+		{&Builtin{Name: "len"}, "builtin function len"},
+		{&ReturnValue{Value: &Integer{Value: 1}}, "1"},
+		{&Error{Message: "boom"}, "ERROR: boom"},
+		{&Error{Message: "boom", Pos: token.Position{Offset: 4, Line: 2, Column: 3}}, "ERROR: 2:3: boom"},
+	}
+
+	for _, tt := range tests {
+		if tt.obj.Inspect() != tt.expected {
+			t.Errorf("%T.Inspect() wrong. expected=%q, got=%q", tt.obj, tt.expected, tt.obj.Inspect())
+		}
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("y", &Integer{Value: 2})
+
+	if _, ok := inner.Get("x"); !ok {
+		t.Errorf("inner environment can not see outer binding")
+	}
+	if _, ok := outer.Get("y"); ok {
+		t.Errorf("outer environment can see inner binding")
+	}
+
+	// Assignは束縛されているスコープで更新する
+	if !inner.Assign("x", &Integer{Value: 10}) {
+		t.Fatalf("Assign(x) returned false")
+	}
+	x, _ := outer.Get("x")
+	if x.(*Integer).Value != 10 {
+		t.Errorf("outer x not updated. got=%s", x.Inspect())
+	}
+	if inner.Assign("z", &Integer{Value: 1}) {
+		t.Errorf("Assign(z) returned true for unbound name")
+	}
+}
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("b", &Integer{Value: 1})
+	outer.Set("a", &Integer{Value: 2})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("c", &Integer{Value: 3})
+	inner.Set("a", &Integer{Value: 4})
+
+	names := inner.Names()
+	if strings.Join(names, ",") != "a,b,c" {
+		t.Errorf("Names() wrong. got=%q", names)
+	}
+}
+
+func TestEnvironmentOutput(t *testing.T) {
+	outer := NewEnvironment()
+	if outer.Output() != os.Stdout {
+		t.Errorf("default Output() is not os.Stdout. got=%v", outer.Output())
+	}
+
+	var out, other bytes.Buffer
+	outer.SetOutput(&out)
+	inner := NewEnclosedEnvironment(outer)
+	if inner.Output() != &out {
+		t.Errorf("inner Output() does not inherit outer output")
+	}
+
+	inner.SetOutput(&other)
+	if inner.Output() != &other || outer.Output() != &out {
+		t.Errorf("SetOutput on inner changed outer output")
+	}
+}
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyDistinguishesTypes(t *testing.T) {
+	keys := []Hashable{
+		&Integer{Value: 1},
+		&Boolean{Value: true},
+		&String{Value: "1"},
+	}
+
+	seen := map[HashKey]Hashable{}
+	for _, key := range keys {
+		if other, ok := seen[key.HashKey()]; ok {
+			t.Errorf("%T and %T have same hash key", key, other)
+		}
+		seen[key.HashKey()] = key
+	}
+
+	if (&Integer{Value: -1}).HashKey() != (&Integer{Value: -1}).HashKey() {
+		t.Errorf("integers with same value have different hash keys")
+	}
+	if (&Boolean{Value: false}).HashKey() == (&Boolean{Value: true}).HashKey() {
+		t.Errorf("true and false have same hash key")
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	one := &String{Value: "one"}
+	two := &String{Value: "two"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{
+		two.HashKey(): {Key: two, Value: &Integer{Value: 2}},
+		one.HashKey(): {Key: one, Value: &Integer{Value: 1}},
+	}}
+
+	if hash.Inspect() != "{one: 1, two: 2}" {
+		t.Errorf("hash.Inspect() wrong. got=%q", hash.Inspect())
+	}
+	if (&Hash{}).Inspect() != "{}" {
+		t.Errorf("empty hash Inspect() wrong. got=%q", (&Hash{}).Inspect())
+	}
+}
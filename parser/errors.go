@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koolii/go-monkey/token"
+)
+
+// ErrorCode 構文解析エラーの種類
+type ErrorCode int
+
+const (
+	_ ErrorCode = iota
+	// ErrLexical 字句解析器が報告したエラー(不正な文字、閉じられていない文字列など)
+	ErrLexical
+	// ErrUnexpectedToken 期待したトークンと異なるトークンが来た
+	ErrUnexpectedToken
+	// ErrNoPrefixParseFn 式を始められないトークンが来た
+	ErrNoPrefixParseFn
+	// ErrInvalidNumber 数値リテラルとして解釈できない
+	ErrInvalidNumber
+	// ErrNumberOutOfRange 数値リテラルが表現できる範囲を超えている
+	ErrNumberOutOfRange
+	// ErrInvalidAssignment 代入の左辺が識別子ではない
+	ErrInvalidAssignment
+	// ErrTrailingComma 閉じ括弧の直前にカンマがある
+	ErrTrailingComma
+	// ErrMissingComma リストの要素の間にカンマがない
+	ErrMissingComma
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	ErrLexical:           "lexical error",
+	ErrUnexpectedToken:   "unexpected token",
+	ErrNoPrefixParseFn:   "no prefix parse function",
+	ErrInvalidNumber:     "invalid number",
+	ErrNumberOutOfRange:  "number out of range",
+	ErrInvalidAssignment: "invalid assignment",
+	ErrTrailingComma:     "trailing comma",
+	ErrMissingComma:      "missing comma",
+}
+
+func (c ErrorCode) String() string {
+	if name, ok := errorCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(c))
+}
+
+// ParseError 構文解析中に見つかったエラー
+// Pos/Endはエラーの原因となったトークンの範囲(Endは含まない)
+// ExpectedとActualは該当する場合だけ設定され、それ以外は空文字列になる
+type ParseError struct {
+	Pos      token.Position
+	End      token.Position
+	Code     ErrorCode
+	Expected token.TokenType
+	Actual   token.TokenType
+	Msg      string
+	// Incomplete 入力が途中で終わっていることが原因のエラー
+	Incomplete bool
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Snippet エラーのある行を表示し、その下の行で問題の箇所を ^ で示す
+// sourceには構文解析した入力全体を渡す
+//
+//	let x 5;
+//	      ^
+func (e *ParseError) Snippet(source string) string {
+	if !e.Pos.IsValid() {
+		return ""
+	}
+
+	offset := e.Pos.Offset
+	if offset > len(source) {
+		offset = len(source)
+	}
+	start := strings.LastIndexByte(source[:offset], '\n') + 1
+	end := strings.IndexByte(source[offset:], '\n')
+	if end < 0 {
+		end = len(source)
+	} else {
+		end += offset
+	}
+	line := strings.TrimRight(source[start:end], "\r")
+
+	// タブはそのまま残して、キャレットの位置が行とずれないようにする
+	// 全角文字も1文字分の空白に置き換えるので、表示幅は考慮していない
+	var caret strings.Builder
+	for _, ch := range source[start:offset] {
+		if ch == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteString("^")
+	// 同じ行で終わるトークンは範囲全体に下線を引く
+	if e.End.Line == e.Pos.Line && e.End.Column > e.Pos.Column+1 {
+		caret.WriteString(strings.Repeat("~", e.End.Column-e.Pos.Column-1))
+	}
+
+	return line + "\n" + caret.String()
+}
@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
 
 	"github.com/koolii/go-monkey/ast"
 	"github.com/koolii/go-monkey/lexer"
@@ -13,17 +16,33 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x += 1 (右結合)
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
+	SUM         // + or - or | or ^
+	PRODUCT     // * or / or % or << or >> or &
 	PREFIX      // -X or !X
+	POWER       // X ** Y (右結合、-X ** Y は -(X ** Y))
 	CALL        // myFunc(x)
+	INDEX       // array[index]
 )
 
+// MaxErrors 報告するエラーの最大数
+// これを超えると構文解析を打ち切り、それまでに作ったASTを返す
+const MaxErrors = 10
+
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []*ParseError
+
+	// 取り込み済みの字句解析エラーの数
+	lexerErrors int
+	// 回復済みのエラーの数(これを超えた分のエラーが起きたら文の境界まで読み飛ばす)
+	recovered int
+	// 解析中のブロックの深さ(0ならトップレベル)
+	blockDepth int
 
 	// Lexerで言うところの position/readPositionのような動き
 	// Lexerは次に読み込む無加工の1文字だったが、今回は文字ではなくtokenになる
@@ -38,11 +57,17 @@ type Parser struct {
 	// - トークンを進めすぎては行けない
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// WithTraceで指定された書き出し先と、トレースの字下げの深さ
+	traceOut   io.Writer
+	traceLevel int
 }
 
-func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
-	fmt.Printf("Parser: %+v", p)
+func New(l *lexer.Lexer, opts ...Option) *Parser {
+	p := &Parser{l: l, errors: []*ParseError{}}
+	for _, opt := range opts {
+		opt(p)
+	}
 
 	// curToken/peekTokenを読み込む
 	p.nextToken()
@@ -56,8 +81,17 @@ func New(l *lexer.Lexer) *Parser {
 	// expressionをParseする際にここに登録してある関数を実行し、Expressionを取得
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
+	p.registerPrefix(token.TRUE, p.parseBoolean)
+	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
@@ -67,17 +101,87 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 
 	return p
 }
 
+// Errors 構文解析中に発生したエラーのメッセージ
+// それぞれのメッセージは "行:列: " で始まる
+// エラーの種類や位置が必要な場合はParseErrorsを使う
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		msgs[i] = err.Error()
+	}
+	return msgs
+}
+
+// ParseErrors 構文解析中に発生したエラー
+func (p *Parser) ParseErrors() []*ParseError {
 	return p.errors
 }
 
+// Incomplete 入力が途中で終わっているためにエラーになったかどうか
+// 閉じられていない括弧・ブロック・文字列や、EOF直前の演算子などが該当する
+// すべてのエラーがEOFによるものの場合だけtrueになり、
+// REPLは続きの行を読み込むかどうかの判断に使う
+func (p *Parser) Incomplete() bool {
+	for _, err := range p.errors {
+		if !err.Incomplete {
+			return false
+		}
+	}
+	return len(p.errors) > 0
+}
+
+// addError tokの位置でエラーを記録する
+// tokがEOFの場合は入力が途中で終わっていることによるエラーになる
+func (p *Parser) addError(code ErrorCode, tok token.Token, format string, a ...interface{}) *ParseError {
+	err := &ParseError{
+		Pos:        tok.Pos,
+		End:        tok.End,
+		Code:       code,
+		Actual:     tok.Type,
+		Msg:        fmt.Sprintf(format, a...),
+		Incomplete: tok.Type == token.EOF,
+	}
+	p.appendError(err)
+	return err
+}
+
+// appendError MaxErrorsに達した後のエラーは捨てる
+func (p *Parser) appendError(err *ParseError) {
+	if len(p.errors) < MaxErrors {
+		p.errors = append(p.errors, err)
+	}
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.unexpectedTokenError(t, p.peekToken)
+}
+
+func (p *Parser) unexpectedTokenError(t token.TokenType, got token.Token) {
+	err := p.addError(ErrUnexpectedToken, got, "expected next token to be %s, got %s instead", t, got.Type)
+	err.Expected = t
 }
 
 // 次のtokenに移動する
@@ -85,6 +189,22 @@ func (p *Parser) nextToken() {
 	// ?構造体を生成したタイミングで peekToken等も初期化される？
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	// コメントは構文解析では使わないので読み飛ばす
+	for p.peekToken.Type == token.COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
+
+	// 字句解析器が報告したエラーも構文解析エラーとして扱う
+	for _, err := range p.l.Errors()[p.lexerErrors:] {
+		p.appendError(&ParseError{
+			Pos:        err.Pos,
+			Code:       ErrLexical,
+			Actual:     token.ILLEGAL,
+			Msg:        err.Msg,
+			Incomplete: err.Incomplete,
+		})
+	}
+	p.lexerErrors = len(p.l.Errors())
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -93,25 +213,84 @@ func (p *Parser) ParseProgram() *ast.Program {
 	// 空のスライスで初期化
 	program.Statements = []ast.Statement{}
 
-	for p.curToken.Type != token.EOF {
+	// エラーが多すぎる場合は打ち切る
+	for p.curToken.Type != token.EOF && len(p.errors) < MaxErrors {
 		// 一文をパース
 		stmt := p.parseStatement()
-		if stmt != nil {
-			// 追加
-			program.Statements = append(program.Statements, stmt)
-		}
+		// 追加
+		program.Statements = append(program.Statements, stmt)
 		p.nextToken()
 	}
 	return program
 }
 
+// parseStatement 一文を解析し、エラーがあれば次の文の境界まで読み飛ばす(パニックモードの回復)
+// 解析できなかった文はBadStatementになる
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.curToken
+	stmt := p.parseStatementNode()
+
+	if len(p.errors) > p.recovered {
+		p.synchronize()
+		p.recovered = len(p.errors)
+	}
+	if stmt == nil {
+		// 読み飛ばしたトークンまでを範囲にする
+		return &ast.BadStatement{Token: start, To: p.curToken.End}
+	}
+	return stmt
+}
+
+// synchronize 文の境界までトークンを読み飛ばす
+// curTokenが ; になるか、peekTokenが文を始めるキーワード・EOFになったところで止まる
+// ブロックの中ではpeekTokenがブロックを閉じる } になったところでも止まる
+// 読み飛ばす途中の { } は対応する } までまとめて飛ばすので、関数やifの本体の } では止まらない
+// 止まった後にnextToken()すれば次の文の最初のトークンに進む
+func (p *Parser) synchronize() {
+	// 読み飛ばしている間に開いた { の数
+	braces := 0
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			braces++
+		case token.RBRACE:
+			if braces > 0 {
+				braces--
+			}
+		case token.SEMICOLON:
+			// トップレベルの ; の後に続く対応のない } もまとめて読み飛ばす
+			if braces == 0 && !(p.blockDepth == 0 && p.peekTokenIs(token.RBRACE)) {
+				return
+			}
+		}
+
+		if braces == 0 {
+			switch p.peekToken.Type {
+			case token.LET, token.RETURN, token.EOF:
+				return
+			case token.RBRACE:
+				if p.blockDepth > 0 {
+					return
+				}
+			}
+		}
+		p.nextToken()
+	}
+}
+
+// 解析できなかった場合はnilを返す
+func (p *Parser) parseStatementNode() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		// ここのReturnTypeが ast.Statementになっているが、
 		// これを *ast.Statementにするとエラーとなる
 		// よく分かっていないが、 Statement < LetStatementの構成だが、だが、ポインタを利用すると継承？がうまく出来ない？
-		return p.parseLetStatement()
+		stmt := p.parseLetStatement()
+		// nilの*ast.LetStatementをそのまま返すとnilのStatementにならない
+		if stmt == nil {
+			return nil
+		}
+		return stmt
 	case token.RETURN:
 		return p.parseReturnStatement()
 	default:
@@ -120,6 +299,8 @@ func (p *Parser) parseStatement() ast.Statement {
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
+	defer p.untrace(p.trace("parseLetStatement"))
+
 	stmt := &ast.LetStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
@@ -133,9 +314,13 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		return nil
 	}
 
-	// 一旦セミコロンまでスキップ
-	for !p.curTokenIs(token.SEMICOLON) {
-		fmt.Printf("token is not semicolon: %+v\n", p)
+	// = の次の式まで移動させる
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	// 式文と同じくセミコロンは省略できる
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -143,14 +328,16 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	defer p.untrace(p.trace("parseReturnStatement"))
+
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
 	// returnトークンの次のexpressionのセクションまで移動させる
 	p.nextToken()
 
-	// 一旦セミコロンまでスキップ
-	for !p.curTokenIs(token.SEMICOLON) {
-		fmt.Printf("token is not semicolon: %+v\n", p)
+	stmt.ReturnValue = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -193,6 +380,8 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	defer p.untrace(p.trace("parseExpressionStatement"))
+
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
 	// もしもセミコロンがなかったとしても問題はない
@@ -207,15 +396,21 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 // p.curToken.Typeの前置に関連付けられた構文解析関数があるかを確認している
 // TODO ここの中身分かってない
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	defer p.untrace(p.trace("parseExpression(" + precedenceName(precedence) + ")"))
+
+	start := p.curToken
 	prefix := p.prefixParseFns[p.curToken.Type]
 
 	if prefix == nil {
 		// 該当する prefxの演算子が存在しなかった場合(プログラムが解釈出来ないと判断される)
-		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		p.noPrefixParseFnError(p.curToken)
+		return &ast.BadExpression{Token: start, To: p.curToken.End}
 	}
 	leftExp := prefix()
-	fmt.Printf("parseExpression(leftExp): %+v\n", leftExp)
+	// 解析に失敗した式はBadExpressionにして、ASTにnilが入らないようにする
+	if leftExp == nil {
+		return &ast.BadExpression{Token: start, To: p.curToken.End}
+	}
 
 	// 2.6.9 中置演算子対応
 	// TODO マジでなんで動くの？
@@ -229,28 +424,53 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		p.nextToken()
 
 		leftExp = infix(leftExp)
+		if leftExp == nil {
+			return &ast.BadExpression{Token: start, To: p.curToken.End}
+		}
 	}
 
 	return leftExp
 }
 
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+func (p *Parser) noPrefixParseFnError(tok token.Token) {
+	// ILLEGALトークンは字句解析器が既にエラーを報告している
+	if tok.Type == token.ILLEGAL {
+		return
+	}
+	p.addError(ErrNoPrefixParseFn, tok, "no prefix parse function for %s found", tok.Type)
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
+	defer p.untrace(p.trace("parseIdentifier"))
+
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
 // 2.6.7
 func (p *Parser) parseIntegerLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseIntegerLiteral"))
+
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	value, err := parseInteger(p.curToken.Literal)
+	if err != nil {
+		p.numberError(err, "integer")
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseFloatLiteral"))
+
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := parseFloat(p.curToken.Literal)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.numberError(err, "float")
 		return nil
 	}
 
@@ -259,8 +479,77 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// numberError 数値リテラルの変換エラーを範囲外とそれ以外で区別して報告する
+func (p *Parser) numberError(err error, kind string) {
+	if errors.Is(err, strconv.ErrRange) {
+		p.addError(ErrNumberOutOfRange, p.curToken, "%s literal %q is out of range", kind, p.curToken.Literal)
+		return
+	}
+	p.addError(ErrInvalidNumber, p.curToken, "could not parse %q as %s", p.curToken.Literal, kind)
+}
+
+// parseInteger 0x/0o/0bの接頭辞と桁区切りの_を解釈して整数に変換する
+// 接頭辞のない場合は先頭が0でも10進数として扱う
+func parseInteger(literal string) (int64, error) {
+	base := 10
+	digits := literal
+	if len(literal) > 1 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			digits = literal[2:]
+		}
+	}
+
+	if !validSeparators(digits, base == 16) {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseInt(strings.ReplaceAll(digits, "_", ""), base, 64)
+}
+
+func parseFloat(literal string) (float64, error) {
+	if !validSeparators(literal, false) {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(literal, "_", ""), 64)
+}
+
+// validSeparators 桁区切りの_が数字と数字の間にだけあるかどうか
+func validSeparators(digits string, hex bool) bool {
+	isDigit := func(ch byte) bool {
+		if '0' <= ch && ch <= '9' {
+			return true
+		}
+		return hex && ('a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F')
+	}
+
+	for i := 0; i < len(digits); i++ {
+		if digits[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(digits)-1 || !isDigit(digits[i-1]) || !isDigit(digits[i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseStringLiteral"))
+
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 // 2.6.8
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	defer p.untrace(p.trace("parsePrefixExpression"))
+
 	expression := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
 
 	// getting Right expression
@@ -275,14 +564,31 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 // 優先順位のマッピング
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.BIT_OR:          SUM,
+	token.BIT_XOR:         SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.SHIFT_LEFT:      PRODUCT,
+	token.SHIFT_RIGHT:     PRODUCT,
+	token.BIT_AND:         PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
 }
 
 // 次のトークン
@@ -304,11 +610,353 @@ func (p *Parser) curPrecedence() int {
 // parsePrefixExpression()との違いは、引数引数としてleftを取ること
 // InfixExpressionを生成するのに必要
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseInfixExpression"))
+
 	expression := &ast.InfixExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: left}
 
 	precedence := p.curPrecedence()
+	// ** は右結合なので、優先順位を一つ下げて同じ演算子を右側に結合させる
+	// 2 ** 3 ** 2 => (2 ** (3 ** 2))
+	if p.curTokenIs(token.POWER) {
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
 	return expression
 }
+
+// x += 1 の構文解析
+// 代入は右結合なので、右側はLOWESTから解析する
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseAssignExpression"))
+
+	// (x) += 1 のように括弧で囲まれていても代入できる
+	for {
+		paren, ok := left.(*ast.ParenExpression)
+		if !ok {
+			break
+		}
+		left = paren.Expression
+	}
+
+	name, ok := left.(*ast.Identifier)
+	if !ok {
+		p.addError(ErrInvalidAssignment, p.curToken, "left side of %s must be an identifier", p.curToken.Literal)
+		return nil
+	}
+
+	expression := &ast.AssignExpression{Token: p.curToken, Name: name, Operator: p.curToken.Literal}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	return expression
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	defer p.untrace(p.trace("parseBoolean"))
+
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+}
+
+// ( に対応する前置構文解析関数
+// 括弧の中をLOWESTから解析し直すことで優先順位を上書きする
+// 括弧の位置を範囲に含めるため、ParenExpressionで包んで返す
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	defer p.untrace(p.trace("parseGroupedExpression"))
+
+	paren := &ast.ParenExpression{Token: p.curToken}
+
+	p.nextToken()
+
+	paren.Expression = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	paren.Rparen = p.curToken.Pos
+
+	return paren
+}
+
+func (p *Parser) parseIfExpression() ast.Expression {
+	defer p.untrace(p.trace("parseIfExpression"))
+
+	expression := &ast.IfExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Consequence = p.parseBlockStatement()
+
+	// elseは省略できる
+	if p.peekTokenIs(token.ELSE) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Alternative = p.parseBlockStatement()
+	}
+
+	return expression
+}
+
+// { から } までの文を解析する
+// 終了時は } がcurTokenにセットされた状態になる
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	defer p.untrace(p.trace("parseBlockStatement"))
+
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		block.Statements = append(block.Statements, p.parseStatement())
+		p.nextToken()
+	}
+	if p.curTokenIs(token.RBRACE) {
+		block.Rbrace = p.curToken.Pos
+	}
+
+	// } で閉じられないままEOFに達した
+	if p.curTokenIs(token.EOF) {
+		p.unexpectedTokenError(token.RBRACE, p.curToken)
+	}
+
+	return block
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseFunctionLiteral"))
+
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+// ( から ) までの仮引数を解析する
+// エラーの場合はnil、仮引数がない場合は空のスライスを返す
+func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+	identifiers := []*ast.Identifier{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return identifiers
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	identifiers = append(identifiers, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) {
+			p.trailingCommaError(token.RPAREN, "parameter list")
+			return nil
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		identifiers = append(identifiers, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.listEndError(token.RPAREN, "parameter list", p.peekTokenIs(token.IDENT))
+		return nil
+	}
+	p.nextToken()
+
+	return identifiers
+}
+
+// ( は中置演算子として関数呼び出しになる
+// functionは識別子・関数リテラル・呼び出し式など関数を返す式
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseCallExpression"))
+
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseExpressionList(token.RPAREN, "argument list")
+	if exp.Arguments == nil {
+		return nil
+	}
+	exp.Rparen = p.curToken.Pos
+	return exp
+}
+
+// [ から ] までの要素を解析する
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseArrayLiteral"))
+
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET, "array literal")
+	if array.Elements == nil {
+		return nil
+	}
+	array.Rbracket = p.curToken.Pos
+	return array
+}
+
+// { から } までのキーと値の組を解析する
+func (p *Parser) parseHashLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseHashLiteral"))
+
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []*ast.HashPair{}}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, &ast.HashPair{Key: key, Value: value})
+
+		if p.peekTokenIs(token.RBRACE) {
+			break
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			// 次のトークンが式を始められるならカンマを忘れている
+			_, startsExpression := p.prefixParseFns[p.peekToken.Type]
+			p.listEndError(token.RBRACE, "hash literal", startsExpression)
+			return nil
+		}
+		p.nextToken()
+		if p.peekTokenIs(token.RBRACE) {
+			p.trailingCommaError(token.RBRACE, "hash literal")
+			return nil
+		}
+	}
+
+	// } まで進める
+	p.nextToken()
+	hash.Rbrace = p.curToken.Pos
+
+	return hash
+}
+
+// [ は中置演算子として添字アクセスかスライスになる
+// arr[i] はIndexExpression、arr[low:high] はSliceExpression(lowとhighは省略できる)
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseIndexExpression"))
+
+	tok := p.curToken
+
+	var low ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		low = p.parseExpression(LOWEST)
+	}
+
+	if !p.peekTokenIs(token.COLON) {
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return &ast.IndexExpression{Token: tok, Left: left, Index: low, Rbracket: p.curToken.Pos}
+	}
+
+	// : まで進める
+	p.nextToken()
+	slice := &ast.SliceExpression{Token: tok, Left: left, Low: low}
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		slice.High = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	slice.Rbracket = p.curToken.Pos
+	return slice
+}
+
+// カンマ区切りの式をendまで解析する
+// エラーの場合はnil、要素がない場合は空のスライスを返す
+func (p *Parser) parseExpressionList(end token.TokenType, context string) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(end) {
+			p.trailingCommaError(end, context)
+			return nil
+		}
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.peekTokenIs(end) {
+		// 次のトークンが式を始められるならカンマを忘れている
+		_, startsExpression := p.prefixParseFns[p.peekToken.Type]
+		p.listEndError(end, context, startsExpression)
+		return nil
+	}
+	p.nextToken()
+
+	return list
+}
+
+// trailingCommaError 閉じ括弧の直前にカンマがある
+// curTokenがカンマにセットされた状態で呼び出す
+func (p *Parser) trailingCommaError(end token.TokenType, context string) {
+	err := p.addError(ErrTrailingComma, p.curToken, "unexpected trailing comma in %s", context)
+	err.Expected = end
+	// 閉じ括弧まで進めておく
+	p.nextToken()
+}
+
+// listEndError リストの要素の後にカンマも閉じ括弧も来なかった
+// missingCommaが真の場合は次の要素の前にカンマを忘れていると報告する
+func (p *Parser) listEndError(end token.TokenType, context string, missingComma bool) {
+	if !missingComma {
+		p.unexpectedTokenError(end, p.peekToken)
+		return
+	}
+	err := p.addError(ErrMissingComma, p.peekToken, "missing comma before %s in %s", p.peekToken.Literal, context)
+	err.Expected = token.COMMA
+}
@@ -1,11 +1,13 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
 	"github.com/koolii/go-monkey/ast"
 	"github.com/koolii/go-monkey/lexer"
+	"github.com/koolii/go-monkey/token"
 )
 
 func TestLetStatement(t *testing.T) {
@@ -15,11 +17,8 @@ let y = 10;
 let foobar = 838383;
 `
 
-	l := lexer.New(input)
-	p := New(l)
-
 	fmt.Println("parse program")
-	program := p.ParseProgram()
+	p, program := parseProgram(t, input)
 	checkParseErrors(t, p)
 	if program == nil {
 		t.Fatalf("ParseProgram() returned nil")
@@ -31,10 +30,11 @@ let foobar = 838383;
 
 	tests := []struct {
 		expectedIdentifier string
+		expectedValue      interface{}
 	}{
-		{"x"},
-		{"y"},
-		{"foobar"},
+		{"x", 5},
+		{"y", 10},
+		{"foobar", 838383},
 	}
 
 	for i, tt := range tests {
@@ -43,6 +43,47 @@ let foobar = 838383;
 		if !testLetStatement(t, stmt, tt.expectedIdentifier) {
 			return
 		}
+
+		value := stmt.(*ast.LetStatement).Value
+		if !testLiteralExpression(t, value, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+// セミコロンは省略でき、値には任意の式を書ける
+func TestLetStatementValues(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedValue      string
+	}{
+		{"let x = 5", "x", "5"},
+		{"let y = x", "y", "x"},
+		{"let z = a + b * c;", "z", "(a + (b * c))"},
+		{"let s = \"str\"", "s", `"str"`},
+		{"let n = -1 ** 2", "n", "(-(1 ** 2))"},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+		stmt := program.Statements[0]
+		if !testLetStatement(t, stmt, tt.expectedIdentifier) {
+			return
+		}
+
+		value := stmt.(*ast.LetStatement).Value
+		if value == nil {
+			t.Fatalf("letStmt.Value is nil")
+		}
+		if value.String() != tt.expectedValue {
+			t.Errorf("letStmt.Value.String() not %q. got=%q", tt.expectedValue, value.String())
+		}
 	}
 }
 
@@ -59,6 +100,53 @@ func checkParseErrors(t *testing.T, p *Parser) {
 	t.FailNow()
 }
 
+// parseProgram inputを構文解析する
+// 構文解析のテストはすべてこれを通すので、テストの入力はどれもJSONで往復できることを確かめられる
+func parseProgram(t *testing.T, input string, opts ...Option) (*Parser, *ast.Program) {
+	t.Helper()
+	p := New(lexer.New(input), opts...)
+	program := p.ParseProgram()
+	checkJSONRoundTrip(t, input, program)
+	return p, program
+}
+
+// checkJSONRoundTrip programをJSONにして読み戻し、同じASTになることを確かめる
+func checkJSONRoundTrip(t *testing.T, input string, program *ast.Program) {
+	t.Helper()
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		t.Errorf("input %q: EncodeJSON failed: %v", input, err)
+		return
+	}
+	decoded, err := ast.DecodeJSON(data)
+	if err != nil {
+		t.Errorf("input %q: DecodeJSON failed: %v\n%s", input, err, data)
+		return
+	}
+	if _, ok := decoded.(*ast.Program); !ok {
+		t.Errorf("input %q: decoded node is not *ast.Program. got=%T", input, decoded)
+		return
+	}
+
+	if decoded.String() != program.String() {
+		t.Errorf("input %q: String() wrong after round trip. expected=%q, got=%q", input, program.String(), decoded.String())
+	}
+	if decoded.Pos() != program.Pos() || decoded.End() != program.End() {
+		t.Errorf("input %q: span wrong after round trip. expected=%s-%s, got=%s-%s",
+			input, program.Pos(), program.End(), decoded.Pos(), decoded.End())
+	}
+
+	// トークンと位置も含めて同じASTになっていれば、もう一度エンコードしても同じJSONになる
+	again, err := ast.EncodeJSON(decoded)
+	if err != nil {
+		t.Errorf("input %q: EncodeJSON of decoded program failed: %v", input, err)
+		return
+	}
+	if !bytes.Equal(again, data) {
+		t.Errorf("input %q: JSON changed after round trip.\nexpected=%s\ngot=%s", input, data, again)
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q\n", s.TokenLiteral())
@@ -97,9 +185,7 @@ return 10;
 return 993322;
 `
 
-	l := lexer.New(input)
-	p := New(l)
-	program := p.ParseProgram()
+	p, program := parseProgram(t, input)
 	checkParseErrors(t, p)
 
 	if program == nil {
@@ -110,7 +196,9 @@ return 993322;
 		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
 	}
 
-	for _, stmt := range program.Statements {
+	expectedValues := []int64{5, 10, 993322}
+
+	for i, stmt := range program.Statements {
 		returnStmt, ok := stmt.(*ast.ReturnStatement)
 		if !ok {
 			t.Errorf("stmt not *ast.ReturnStatement. got=%T", stmt)
@@ -120,6 +208,57 @@ return 993322;
 		if returnStmt.TokenLiteral() != "return" {
 			t.Errorf("returnStmt.TokenLiteral not 'return', got %q", returnStmt.TokenLiteral())
 		}
+		testIntegerLiteral(t, returnStmt.ReturnValue, expectedValues[i])
+	}
+}
+
+func TestReturnStatementValues(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue string
+	}{
+		{"return x", "x"},
+		{"return a + b;", "(a + b)"},
+		{"return x; return y", "x"},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		returnStmt, ok := program.Statements[0].(*ast.ReturnStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ReturnStatement. got=%T", program.Statements[0])
+		}
+		if returnStmt.ReturnValue == nil {
+			t.Fatalf("returnStmt.ReturnValue is nil")
+		}
+		if returnStmt.ReturnValue.String() != tt.expectedValue {
+			t.Errorf("returnStmt.ReturnValue.String() not %q. got=%q", tt.expectedValue, returnStmt.ReturnValue.String())
+		}
+	}
+}
+
+// 式がないままEOFに達した場合もエラーを報告して終了する
+func TestLetAndReturnStatementAtEOF(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x =", "1:8: no prefix parse function for EOF found"},
+		{"return", "1:7: no prefix parse function for EOF found"},
+	}
+
+	for _, tt := range tests {
+		p, _ := parseProgram(t, tt.input)
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("input %q - expected 1 parser error. got=%d (%q)", tt.input, len(errors), errors)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("input %q - error wrong. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
 	}
 }
 
@@ -128,9 +267,7 @@ return 993322;
 func TestIdentifierExpression(t *testing.T) {
 	input := `foobar;`
 
-	l := lexer.New(input)
-	p := New(l)
-	program := p.ParseProgram()
+	p, program := parseProgram(t, input)
 	checkParseErrors(t, p)
 	if program == nil {
 		t.Fatalf("ParseProgram() returned nil")
@@ -162,9 +299,7 @@ func TestIdentifierExpression(t *testing.T) {
 func TestIntegerLiteralExpression(t *testing.T) {
 	input := "5;"
 
-	l := lexer.New(input)
-	p := New(l)
-	program := p.ParseProgram()
+	p, program := parseProgram(t, input)
 	checkParseErrors(t, p)
 
 	if len(program.Statements) != 1 {
@@ -188,6 +323,130 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0", 0},
+		{"007", 7},
+		{"1_000_000", 1000000},
+		{"0xFF", 255},
+		{"0Xff", 255},
+		{"0o755", 493},
+		{"0b1010", 10},
+		{"9223372036854775807", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("input %q - literal.Value not %d. got=%d", tt.input, tt.expected, literal.Value)
+		}
+		// 元の表記のまま出力される
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %q. got=%q", tt.input, literal.String())
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"2.5E+3", 2500},
+		{"1_000.000_1", 1000.0001},
+		{"0.5", 0.5},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("input %q - literal.Value not %g. got=%g", tt.input, tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %q. got=%q", tt.input, literal.String())
+		}
+	}
+}
+
+func TestNumberLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", `1:1: integer literal "9223372036854775808" is out of range`},
+		{"x + 0xFFFFFFFFFFFFFFFFF", `1:5: integer literal "0xFFFFFFFFFFFFFFFFF" is out of range`},
+		{"1e400", `1:1: float literal "1e400" is out of range`},
+		{"0x", `1:1: could not parse "0x" as integer`},
+		{"0b102", `1:1: could not parse "0b102" as integer`},
+		{"0o8", `1:1: could not parse "0o8" as integer`},
+		{"123abc", `1:1: could not parse "123abc" as integer`},
+		{"1__000", `1:1: could not parse "1__000" as integer`},
+		{"1000_", `1:1: could not parse "1000_" as integer`},
+		{"0b_1", `1:1: could not parse "0b_1" as integer`},
+		{"1_.5", `1:1: could not parse "1_.5" as float`},
+		{"1e", `1:1: could not parse "1e" as float`},
+	}
+
+	for _, tt := range tests {
+		p, _ := parseProgram(t, tt.input)
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("input %q - expected 1 parser error. got=%d (%q)", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("input %q - error wrong. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello\tworld \u{3042}";`
+
+	p, program := parseProgram(t, input)
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != "hello\tworld あ" {
+		t.Errorf("literal.Value not %q. got=%q", "hello\tworld あ", literal.Value)
+	}
+	if literal.String() != `"hello\tworld あ"` {
+		t.Errorf("literal.String() not %q. got=%q", `"hello\tworld あ"`, literal.String())
+	}
+}
+
 // 2.6.8 Prefix
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
@@ -200,9 +459,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}
 
 	for _, tt := range prefixTests {
-		l := lexer.New(tt.input)
-		p := New(l)
-		program := p.ParseProgram()
+		p, program := parseProgram(t, tt.input)
 		checkParseErrors(t, p)
 
 		if len(program.Statements) != 1 {
@@ -244,6 +501,75 @@ func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	return true
 }
 
+func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
+	ident, ok := exp.(*ast.Identifier)
+	if !ok {
+		t.Errorf("exp not *ast.Identifier. got=%T", exp)
+		return false
+	}
+	if ident.Value != value {
+		t.Errorf("ident.Value not %s. got=%s", value, ident.Value)
+		return false
+	}
+	if ident.TokenLiteral() != value {
+		t.Errorf("ident.TokenLiteral not %s. got=%s", value, ident.TokenLiteral())
+		return false
+	}
+	return true
+}
+
+// testLiteralExpression 期待値の型に応じて整数リテラルか識別子かを検査する
+func testLiteralExpression(t *testing.T, exp ast.Expression, expected interface{}) bool {
+	switch v := expected.(type) {
+	case int:
+		return testIntegerLiteral(t, exp, int64(v))
+	case int64:
+		return testIntegerLiteral(t, exp, v)
+	case string:
+		return testIdentifier(t, exp, v)
+	case bool:
+		return testBooleanLiteral(t, exp, v)
+	}
+	t.Errorf("type of exp not handled. got=%T", exp)
+	return false
+}
+
+func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
+	bo, ok := exp.(*ast.Boolean)
+	if !ok {
+		t.Errorf("exp not *ast.Boolean. got=%T", exp)
+		return false
+	}
+	if bo.Value != value {
+		t.Errorf("bo.Value not %t. got=%t", value, bo.Value)
+		return false
+	}
+	if bo.TokenLiteral() != fmt.Sprintf("%t", value) {
+		t.Errorf("bo.TokenLiteral not %t. got=%s", value, bo.TokenLiteral())
+		return false
+	}
+	return true
+}
+
+func testInfixExpression(t *testing.T, exp ast.Expression, left interface{}, operator string, right interface{}) bool {
+	opExp, ok := exp.(*ast.InfixExpression)
+	if !ok {
+		t.Errorf("exp is not ast.InfixExpression. got=%T(%s)", exp, exp)
+		return false
+	}
+	if !testLiteralExpression(t, opExp.Left, left) {
+		return false
+	}
+	if opExp.Operator != operator {
+		t.Errorf("exp.Operator is not '%s'. got=%q", operator, opExp.Operator)
+		return false
+	}
+	if !testLiteralExpression(t, opExp.Right, right) {
+		return false
+	}
+	return true
+}
+
 // 2.6.9
 func TestParsingInfixExpressions(t *testing.T) {
 	infixTests := []struct {
@@ -260,12 +586,21 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5", 5, "<", 5},
 		{"5 == 5", 5, "==", 5},
 		{"5 != 5", 5, "!=", 5},
+		{"5 <= 5", 5, "<=", 5},
+		{"5 >= 5", 5, ">=", 5},
+		{"5 && 5", 5, "&&", 5},
+		{"5 || 5", 5, "||", 5},
+		{"5 % 5", 5, "%", 5},
+		{"5 ** 5", 5, "**", 5},
+		{"5 & 5", 5, "&", 5},
+		{"5 | 5", 5, "|", 5},
+		{"5 ^ 5", 5, "^", 5},
+		{"5 << 5", 5, "<<", 5},
+		{"5 >> 5", 5, ">>", 5},
 	}
 
 	for _, tt := range infixTests {
-		l := lexer.New(tt.input)
-		p := New(l)
-		program := p.ParseProgram()
+		p, program := parseProgram(t, tt.input)
 		checkParseErrors(t, p)
 
 		if len(program.Statements) != 1 {
@@ -310,12 +645,42 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
 		{"5 < 4 != 3 > 4", "((5 < 4) != (3 > 4))"},
 		{"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"a < b || a > c", "((a < b) || (a > c))"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a % b * c", "((a % b) * c)"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a ** -b", "(a ** (-b))"},
+		{"a | b ^ c & d", "((a | b) ^ (c & d))"},
+		{"a & b == c", "((a & b) == c)"},
+		{"a << b + c >> d", "((a << b) + (c >> d))"},
+		{"a + b << c", "(a + (b << c))"},
+		{"x += a * b", "(x += (a * b))"},
+		{"x -= y *= 2", "(x -= (y *= 2))"},
+		{"x /= a || b", "(x /= (a || b))"},
+		{"true", "true"},
+		{"false", "false"},
+		{"3 > 5 == false", "((3 > 5) == false)"},
+		{"3 < 5 == true", "((3 < 5) == true)"},
+		{"!true == false", "((!true) == false)"},
+		{"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
+		{"(5 + 5) * 2", "((5 + 5) * 2)"},
+		{"2 / (5 + 5)", "(2 / (5 + 5))"},
+		{"-(5 + 5)", "(-(5 + 5))"},
+		{"!(true == true)", "(!(true == true))"},
+		{"(-a) ** b", "((-a) ** b)"},
+		{"(a ** b) ** c", "((a ** b) ** c)"},
+		{"(a || b) && c", "((a || b) && c)"},
+		{"(x) += 1", "(x += 1)"},
 	}
 
 	for _, tt := range tests {
-		l := lexer.New(tt.input)
-		p := New(l)
-		program := p.ParseProgram()
+		p, program := parseProgram(t, tt.input)
 		checkParseErrors(t, p)
 
 		actual := program.String()
@@ -324,3 +689,1091 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestAssignExpressionErrors(t *testing.T) {
+	input := `1 += 2`
+
+	p, _ := parseProgram(t, input)
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 parser error. got=%d (%q)", len(errors), errors)
+	}
+	expected := "1:3: left side of += must be an identifier"
+	if errors[0] != expected {
+		t.Errorf("errors[0] wrong. expected=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestParserErrorPosition(t *testing.T) {
+	input := `let x = 5;
+let = 10;`
+
+	p, _ := parseProgram(t, input)
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors. got none")
+	}
+	expected := "2:5: expected next token to be IDENT, got = instead"
+	if errors[0] != expected {
+		t.Errorf("errors[0] wrong. expected=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestLexerErrorsAreReported(t *testing.T) {
+	input := `5 + @`
+
+	p, _ := parseProgram(t, input)
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 parser error. got=%d (%q)", len(errors), errors)
+	}
+	expected := "1:5: unexpected character '@'"
+	if errors[0] != expected {
+		t.Errorf("errors[0] wrong. expected=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestNULIsNotEndOfInput(t *testing.T) {
+	input := "let a = 1;\x00 let b = 2; b"
+
+	p, program := parseProgram(t, input)
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 parser error. got=%d (%q)", len(errors), errors)
+	}
+	expected := "1:11: unexpected character '\\x00'"
+	if errors[0] != expected {
+		t.Errorf("errors[0] wrong. expected=%q, got=%q", expected, errors[0])
+	}
+	// NULの後ろも解析が続く
+	if len(program.Statements) != 4 {
+		t.Fatalf("program.Statements does not contain 4 statements. got=%d", len(program.Statements))
+	}
+	if last := program.Statements[3].String(); last != "b" {
+		t.Errorf("program.Statements[3] wrong. expected=%q, got=%q", "b", last)
+	}
+}
+
+func TestCommentsAreSkipped(t *testing.T) {
+	input := `// comment
+a + /* inline */ b * c // trailing`
+
+	for _, l := range []*lexer.Lexer{lexer.New(input), lexer.New(input, lexer.WithComments())} {
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+		checkJSONRoundTrip(t, input, program)
+
+		expected := "(a + (b * c))"
+		if program.String() != expected {
+			t.Errorf("expected=%q, got=%q", expected, program.String())
+		}
+	}
+}
+
+func TestBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedBoolean bool
+	}{
+		{"true;", true},
+		{"false;", false},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		if !testBooleanLiteral(t, stmt.Expression, tt.expectedBoolean) {
+			return
+		}
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	input := `if (x < y) { x }`
+
+	p, program := parseProgram(t, input)
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(exp.Consequence.Statements) != 1 {
+		t.Errorf("consequence is not 1 statements. got=%d\n", len(exp.Consequence.Statements))
+	}
+
+	consequence, ok := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Consequence.Statements[0])
+	}
+	if !testIdentifier(t, consequence.Expression, "x") {
+		return
+	}
+
+	if exp.Alternative != nil {
+		t.Errorf("exp.Alternative.Statements was not nil. got=%+v", exp.Alternative)
+	}
+}
+
+func TestIfElseExpression(t *testing.T) {
+	input := `if (x < y) { x } else { let z = y; z }`
+
+	p, program := parseProgram(t, input)
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	consequence, ok := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Consequence.Statements[0])
+	}
+	if !testIdentifier(t, consequence.Expression, "x") {
+		return
+	}
+
+	if exp.Alternative == nil {
+		t.Fatalf("exp.Alternative is nil")
+	}
+	if len(exp.Alternative.Statements) != 2 {
+		t.Fatalf("alternative is not 2 statements. got=%d\n", len(exp.Alternative.Statements))
+	}
+	if !testLetStatement(t, exp.Alternative.Statements[0], "z") {
+		return
+	}
+
+	alternative, ok := exp.Alternative.Statements[1].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[1] is not ast.ExpressionStatement. got=%T", exp.Alternative.Statements[1])
+	}
+	if !testIdentifier(t, alternative.Expression, "z") {
+		return
+	}
+}
+
+// if式のString()はMonkeyのコードとして構文解析し直せる
+func TestIfExpressionString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (x < y) { x }", "if ((x < y)) { x }"},
+		{"if (true) { {1: 2} }", "if (true) { {1: 2} }"},
+		{"if (x) { } else { let z = y; z }", "if (x) {} else { let z = y; z }"},
+		{"let r = fn(n) { if (n == 0) { 0 } else { r(n - 1) } }", "let r = fn(n) { if ((n == 0)) { 0 } else { r((n - 1)) } };"},
+		{"if (a) { if (b) { 1 } else { 2 } }", "if (a) { if (b) { 1 } else { 2 } }"},
+	}
+
+	for _, tt := range tests {
+		_, program := parseProgram(t, tt.input)
+		if program.String() != tt.expected {
+			t.Errorf("input %q: String() wrong. expected=%q, got=%q", tt.input, tt.expected, program.String())
+			continue
+		}
+
+		// if式の部分を読み直しても同じ文字列になる
+		ifExp := findIfExpression(program)
+		if ifExp == nil {
+			t.Fatalf("input %q: no IfExpression found", tt.input)
+		}
+		p, reparsed := parseProgram(t, ifExp.String())
+		checkParseErrors(t, p)
+		if reparsed.String() != ifExp.String() {
+			t.Errorf("input %q: reparsed String() wrong. expected=%q, got=%q", tt.input, ifExp.String(), reparsed.String())
+		}
+	}
+}
+
+func findIfExpression(node ast.Node) *ast.IfExpression {
+	var found *ast.IfExpression
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ie, ok := n.(*ast.IfExpression); ok && found == nil {
+			found = ie
+		}
+		return found == nil
+	})
+	return found
+}
+
+func TestIfExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if x { 1 }", "1:4: expected next token to be (, got IDENT instead"},
+		{"if (x { 1 }", "1:7: expected next token to be ), got { instead"},
+		{"if (x) 1", "1:8: expected next token to be {, got INT instead"},
+		{"if (x) { 1 } else 2", "1:19: expected next token to be {, got INT instead"},
+		{"if (x) { 1", "1:11: expected next token to be }, got EOF instead"},
+		{"(1 + 2", "1:7: expected next token to be ), got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		p, _ := parseProgram(t, tt.input)
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q - expected parser errors. got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("input %q - error wrong. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
+
+	p, program := parseProgram(t, input)
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d\n", len(function.Parameters))
+	}
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n", len(function.Body.Statements))
+	}
+
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	}
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fn() {};", expectedParams: []string{}},
+		{input: "fn(x) {};", expectedParams: []string{"x"}},
+		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d\n", len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	p, program := parseProgram(t, input)
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+func TestCallExpressionPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
+		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
+		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		{"-f(x) ** 2", "(-(f(x) ** 2))"},
+		{"f()", "f()"},
+		// 呼び出し結果の呼び出しと即時実行される関数リテラル
+		{"f(1)(2)", "f(1)(2)"},
+		{"fn(x) { x }(5)", "fn(x) { x }(5)"},
+		{"let r = fn(x, y) { x * y }(2, 3);", "let r = fn(x, y) { (x * y) }(2, 3);"},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestFunctionAndCallErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x,) { x }", "1:5: unexpected trailing comma in parameter list"},
+		{"fn(x y) { x }", "1:6: missing comma before y in parameter list"},
+		{"fn(,x) { x }", "1:4: expected next token to be IDENT, got , instead"},
+		{"fn(x, 1) { x }", "1:7: expected next token to be IDENT, got INT instead"},
+		{"fn(x { x }", "1:6: expected next token to be ), got { instead"},
+		{"add(1, 2,)", "1:9: unexpected trailing comma in argument list"},
+		{"add(1 2)", "1:7: missing comma before 2 in argument list"},
+		{"add(1, 2", "1:9: expected next token to be ), got EOF instead"},
+		{"add(1; 2)", "1:6: expected next token to be ), got ; instead"},
+	}
+
+	for _, tt := range tests {
+		p, _ := parseProgram(t, tt.input)
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q - expected parser errors. got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("input %q - error wrong. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3, fn(x) { x }]"
+
+	p, program := parseProgram(t, input)
+	checkParseErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 4 {
+		t.Fatalf("len(array.Elements) not 4. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+	if _, ok := array.Elements[3].(*ast.FunctionLiteral); !ok {
+		t.Errorf("array.Elements[3] not ast.FunctionLiteral. got=%T", array.Elements[3])
+	}
+}
+
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	p, program := parseProgram(t, "[]")
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	p, program := parseProgram(t, input)
+	checkParseErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		low   interface{} // nilは省略
+		high  interface{}
+	}{
+		{"arr[1:3]", 1, 3},
+		{"arr[1:]", 1, nil},
+		{"arr[:3]", nil, 3},
+		{"arr[:]", nil, nil},
+		{"arr[x:y]", "x", "y"},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		slice, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+		if !testIdentifier(t, slice.Left, "arr") {
+			return
+		}
+
+		if tt.low == nil {
+			if slice.Low != nil {
+				t.Errorf("input %q - slice.Low not nil. got=%s", tt.input, slice.Low)
+			}
+		} else {
+			testLiteralExpression(t, slice.Low, tt.low)
+		}
+		if tt.high == nil {
+			if slice.High != nil {
+				t.Errorf("input %q - slice.High not nil. got=%s", tt.input, slice.High)
+			}
+		} else {
+			testLiteralExpression(t, slice.High, tt.high)
+		}
+	}
+}
+
+func TestIndexExpressionPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"-a[0]", "(-(a[0]))"},
+		{"a[0] ** 2", "((a[0]) ** 2)"},
+		{"f(x)[0]", "(f(x)[0])"},
+		{"a[0][1]", "((a[0])[1])"},
+		{"a[1:][0]", "((a[1:])[0])"},
+		{"a[-1]", "(a[(-1)])"},
+		{"a[1 + 1:len - 1]", "(a[(1 + 1):(len - 1)])"},
+		{"[[1, 2], []][0]", "([[1, 2], []][0])"},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+
+		// String()の結果をもう一度解析しても同じ木になる
+		p, reparsed := parseProgram(t, actual)
+		checkParseErrors(t, p)
+		if reparsed.String() != actual {
+			t.Errorf("round trip wrong. expected=%q, got=%q", actual, reparsed.String())
+		}
+	}
+}
+
+func TestArrayAndIndexErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2,]", "1:6: unexpected trailing comma in array literal"},
+		{"[1 2]", "1:4: missing comma before 2 in array literal"},
+		{"[1, 2", "1:6: expected next token to be ], got EOF instead"},
+		{"a[1", "1:4: expected next token to be ], got EOF instead"},
+		{"a[1 2]", "1:5: expected next token to be ], got INT instead"},
+		{"a[]", "1:3: no prefix parse function for ] found"},
+		{"a[1:2:3]", "1:6: expected next token to be ], got : instead"},
+	}
+
+	for _, tt := range tests {
+		p, _ := parseProgram(t, tt.input)
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q - expected parser errors. got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("input %q - error wrong. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	p, program := parseProgram(t, input)
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	// Pairsは書かれた順に並ぶ
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		if literal.Value != expected[i].key {
+			t.Errorf("key wrong. expected=%q, got=%q", expected[i].key, literal.Value)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	p, program := parseProgram(t, "{}")
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, two: 10 - 8, 1 + 2: 15 / 5, true: [1][0]}`
+
+	p, program := parseProgram(t, input)
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+	if len(hash.Pairs) != 4 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	testInfixExpression(t, hash.Pairs[0].Value, 0, "+", 1)
+	testIdentifier(t, hash.Pairs[1].Key, "two")
+	testInfixExpression(t, hash.Pairs[1].Value, 10, "-", 8)
+	testInfixExpression(t, hash.Pairs[2].Key, 1, "+", 2)
+	testInfixExpression(t, hash.Pairs[2].Value, 15, "/", 5)
+	testBooleanLiteral(t, hash.Pairs[3].Key, true)
+	if _, ok := hash.Pairs[3].Value.(*ast.IndexExpression); !ok {
+		t.Errorf("value is not ast.IndexExpression. got=%T", hash.Pairs[3].Value)
+	}
+}
+
+func TestHashLiteralString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a": 1, "b": 2 * 3}`, `{"a": 1, "b": (2 * 3)}`},
+		{`{}`, `{}`},
+		{`{1: {true: [1, 2]}}["x"]`, `({1: {true: [1, 2]}}["x"])`},
+		{`let h = {"f": fn(x) { x }}`, `let h = {"f": fn(x) { x }};`},
+		{`if (x) { {} }`, `if (x) { {} }`},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a" 1}`, "1:6: expected next token to be :, got INT instead"},
+		{`{"a": 1 "b": 2}`, `1:9: missing comma before b in hash literal`},
+		{`{"a": 1,}`, "1:8: unexpected trailing comma in hash literal"},
+		{`{"a": 1;}`, "1:8: expected next token to be }, got ; instead"},
+		{`{"a": 1`, "1:8: expected next token to be }, got EOF instead"},
+		{`{: 1}`, "1:2: no prefix parse function for : found"},
+	}
+
+	for _, tt := range tests {
+		p, _ := parseProgram(t, tt.input)
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q - expected parser errors. got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("input %q - error wrong. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"1 + 2", false},
+		{"let add = fn(x, y) { x + y };", false},
+		{"1 +", true},
+		{"let x =", true},
+		{"return", true},
+		{"(1 + 2", true},
+		{"(1 + ", true},
+		{"add(1, ", true},
+		{"add(1, 2", true},
+		{"fn(x,", true},
+		{"fn(x) {", true},
+		{"let f = fn(x) {\n  if (x) {\n    1\n  }", true},
+		{"if (x) { 1 } else", true},
+		{`"unterminated`, true},
+		{"1 /* open", true},
+		// 途中に本当のエラーがある場合は続きを読んでも解消しない
+		{"1 + + ", false},
+		{"let = 1; (1 +", false},
+		{"@ (1 +", false},
+		{"add(1 2", false},
+		{"fn(x,) {", false},
+		{"[1, 2", true},
+		{"a[1:", true},
+		{`{"a": 1,`, true},
+		{`{"a":`, true},
+	}
+
+	for _, tt := range tests {
+		p, _ := parseProgram(t, tt.input)
+
+		if p.Incomplete() != tt.incomplete {
+			t.Errorf("input %q - Incomplete() wrong. expected=%t, got=%t (errors=%q)", tt.input, tt.incomplete, p.Incomplete(), p.Errors())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     ErrorCode
+		pos      string
+		expected token.TokenType
+		actual   token.TokenType
+	}{
+		{"let = 5;", ErrUnexpectedToken, "1:5", token.IDENT, token.ASSIGN},
+		{"let x 5;", ErrUnexpectedToken, "1:7", token.ASSIGN, token.INT},
+		{"(1 + 2", ErrUnexpectedToken, "1:7", token.RPAREN, token.EOF},
+		{"1 + * 2", ErrNoPrefixParseFn, "1:5", "", token.ASTERISK},
+		{"99999999999999999999", ErrNumberOutOfRange, "1:1", "", token.INT},
+		{"1e999", ErrNumberOutOfRange, "1:1", "", token.FLOAT},
+		{"0x", ErrInvalidNumber, "1:1", "", token.INT},
+		{"1 += 2", ErrInvalidAssignment, "1:3", "", token.PLUS_ASSIGN},
+		{"add(1,)", ErrTrailingComma, "1:6", token.RPAREN, token.COMMA},
+		{"add(1 2)", ErrMissingComma, "1:7", token.COMMA, token.INT},
+		{"\n  @", ErrLexical, "2:3", "", token.ILLEGAL},
+	}
+
+	for _, tt := range tests {
+		p, _ := parseProgram(t, tt.input)
+
+		errors := p.ParseErrors()
+		if len(errors) == 0 {
+			t.Errorf("input %q - expected parser errors. got none", tt.input)
+			continue
+		}
+		err := errors[0]
+		if err.Code != tt.code {
+			t.Errorf("input %q - Code wrong. expected=%s, got=%s", tt.input, tt.code, err.Code)
+		}
+		if err.Pos.String() != tt.pos {
+			t.Errorf("input %q - Pos wrong. expected=%s, got=%s", tt.input, tt.pos, err.Pos)
+		}
+		if err.Expected != tt.expected {
+			t.Errorf("input %q - Expected wrong. expected=%q, got=%q", tt.input, tt.expected, err.Expected)
+		}
+		if err.Actual != tt.actual {
+			t.Errorf("input %q - Actual wrong. expected=%q, got=%q", tt.input, tt.actual, err.Actual)
+		}
+		// Errors()は同じエラーのメッセージを返す
+		if p.Errors()[0] != err.Error() {
+			t.Errorf("input %q - Errors()[0] wrong. expected=%q, got=%q", tt.input, err.Error(), p.Errors()[0])
+		}
+	}
+}
+
+func TestParseErrorSnippet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "let x 5;\n      ^"},
+		{"let = 5;", "let = 5;\n    ^"},
+		{"1 + 99999999999999999999", "1 + 99999999999999999999\n    ^~~~~~~~~~~~~~~~~~~~"},
+		{"let a = 1;\nlet b 2;\nlet c = 3;", "let b 2;\n      ^"},
+		{"if (x) {\n\tlet y 1;\n}", "\tlet y 1;\n\t      ^"},
+		{"let s = \"文字\" +;", "let s = \"文字\" +;\n              ^"},
+		{"(1 + 2", "(1 + 2\n      ^"},
+	}
+
+	for _, tt := range tests {
+		p, _ := parseProgram(t, tt.input)
+
+		errors := p.ParseErrors()
+		if len(errors) == 0 {
+			t.Errorf("input %q - expected parser errors. got none", tt.input)
+			continue
+		}
+		if snippet := errors[0].Snippet(tt.input); snippet != tt.expected {
+			t.Errorf("input %q - Snippet wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, snippet)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		errors   []string
+	}{
+		// 失敗した文はBadStatementになり、次の文から解析を再開する
+		{
+			"let = 5; let y = 10; y",
+			"<bad statement>let y = 10;y",
+			[]string{"1:5: expected next token to be IDENT, got = instead"},
+		},
+		// セミコロンがなくても次の文のキーワードで再開する
+		{
+			"let x 5 * 2 let y = 1 + * 2; y",
+			"<bad statement>let y = (1 + <bad expression>);y",
+			[]string{
+				"1:7: expected next token to be =, got INT instead",
+				"1:25: no prefix parse function for * found",
+			},
+		},
+		// ブロックの中のエラーは } の手前で回復し、外側の文は解析を続ける
+		{
+			"let f = fn(x) { let = 1; x }; f(1)",
+			"let f = fn(x) { <bad statement>; x };f(1)",
+			[]string{"1:21: expected next token to be IDENT, got = instead"},
+		},
+		{
+			"if (x) { 1 } else 5; let a = 1;",
+			"<bad expression>let a = 1;",
+			[]string{"1:19: expected next token to be {, got INT instead"},
+		},
+		{
+			"add(1 2) return 3",
+			"<bad expression>return 3;",
+			[]string{"1:7: missing comma before 2 in argument list"},
+		},
+		{
+			") ) ) ) ) 1",
+			"<bad expression>",
+			[]string{"1:1: no prefix parse function for ) found"},
+		},
+		// トップレベルでは関数やifの本体の } で止まらず、{ } をまとめて読み飛ばす
+		{
+			"fn(a,) { a }; let y = 1;",
+			"<bad expression>let y = 1;",
+			[]string{"1:5: unexpected trailing comma in parameter list"},
+		},
+		{
+			"fn(x { x }",
+			"<bad expression>",
+			[]string{"1:6: expected next token to be ), got { instead"},
+		},
+		{
+			"if (x { 1 }",
+			"<bad expression>",
+			[]string{"1:7: expected next token to be ), got { instead"},
+		},
+		{
+			"add(1 2) { }",
+			"<bad expression>",
+			[]string{"1:7: missing comma before 2 in argument list"},
+		},
+		{
+			"let x = ; }",
+			"let x = <bad expression>;",
+			[]string{"1:9: no prefix parse function for ; found"},
+		},
+		{
+			"{1 2}",
+			"<bad expression>",
+			[]string{"1:4: expected next token to be :, got INT instead"},
+		},
+		// ブロックの中では、エラーの文の中の { } を飛ばしてからブロックを閉じる } の手前で止まる
+		{
+			"let f = fn(x) { let = 1; if (x { 1 } x }; f(1)",
+			"let f = fn(x) { <bad statement>; <bad expression> };f(1)",
+			[]string{
+				"1:21: expected next token to be IDENT, got = instead",
+				"1:32: expected next token to be ), got { instead",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+
+		if program.String() != tt.expected {
+			t.Errorf("input %q - program wrong. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+		errors := p.Errors()
+		if fmt.Sprint(errors) != fmt.Sprint(tt.errors) {
+			t.Errorf("input %q - errors wrong.\nexpected=%q\ngot=%q", tt.input, tt.errors, errors)
+		}
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	input := ""
+	for i := 0; i < MaxErrors*2; i++ {
+		input += "let = 1;\n"
+	}
+
+	p, program := parseProgram(t, input)
+
+	if len(p.Errors()) != MaxErrors {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d", MaxErrors, len(p.Errors()))
+	}
+	// 上限に達した時点で解析を打ち切る
+	if len(program.Statements) != MaxErrors {
+		t.Errorf("wrong number of statements. expected=%d, got=%d", MaxErrors, len(program.Statements))
+	}
+	for i, stmt := range program.Statements {
+		if _, ok := stmt.(*ast.BadStatement); !ok {
+			t.Errorf("program.Statements[%d] is not *ast.BadStatement. got=%T", i, stmt)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var out bytes.Buffer
+	p, _ := parseProgram(t, "-a + 2 * 3", WithTrace(&out))
+	checkParseErrors(t, p)
+
+	expected := `BEGIN parseExpressionStatement 1:1 - "-"
+  BEGIN parseExpression(LOWEST) 1:1 - "-"
+    BEGIN parsePrefixExpression 1:1 - "-"
+      BEGIN parseExpression(PREFIX) 1:2 IDENT "a"
+        BEGIN parseIdentifier 1:2 IDENT "a"
+        END parseIdentifier
+      END parseExpression(PREFIX)
+    END parsePrefixExpression
+    BEGIN parseInfixExpression 1:4 + "+"
+      BEGIN parseExpression(SUM) 1:6 INT "2"
+        BEGIN parseIntegerLiteral 1:6 INT "2"
+        END parseIntegerLiteral
+        BEGIN parseInfixExpression 1:8 * "*"
+          BEGIN parseExpression(PRODUCT) 1:10 INT "3"
+            BEGIN parseIntegerLiteral 1:10 INT "3"
+            END parseIntegerLiteral
+          END parseExpression(PRODUCT)
+        END parseInfixExpression
+      END parseExpression(SUM)
+    END parseInfixExpression
+  END parseExpression(LOWEST)
+END parseExpressionStatement
+`
+	if out.String() != expected {
+		t.Errorf("trace wrong.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+// ノードの範囲 Pos()〜End() が指すソースの文字列を行きがけ順に並べて確かめる
+func TestNodeSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1 + 2 * 3;", []string{"1 + 2 * 3", "1 + 2 * 3", "1 + 2 * 3", "1", "2 * 3", "2", "3"}},
+		{"let x = -a;", []string{"let x = -a", "let x = -a", "x", "-a", "a"}},
+		{"return x * 2;", []string{"return x * 2", "return x * 2", "x * 2", "x", "2"}},
+		{"x += 1", []string{"x += 1", "x += 1", "x += 1", "x", "1"}},
+		{`"héllo"`, []string{`"héllo"`, `"héllo"`, `"héllo"`}},
+		{"add(1, 2)", []string{"add(1, 2)", "add(1, 2)", "add(1, 2)", "add", "1", "2"}},
+		{"if (x) { y } else { z }", []string{
+			"if (x) { y } else { z }", "if (x) { y } else { z }", "if (x) { y } else { z }",
+			"x", "{ y }", "y", "y", "{ z }", "z", "z",
+		}},
+		{"fn(a) {\n  a;\n}", []string{"fn(a) {\n  a;\n}", "fn(a) {\n  a;\n}", "fn(a) {\n  a;\n}", "a", "{\n  a;\n}", "a", "a"}},
+		{"[1, 2][0]", []string{"[1, 2][0]", "[1, 2][0]", "[1, 2][0]", "[1, 2]", "1", "2", "0"}},
+		{"a[1:]", []string{"a[1:]", "a[1:]", "a[1:]", "a", "1"}},
+		{`{"a": 1}`, []string{`{"a": 1}`, `{"a": 1}`, `{"a": 1}`, `"a"`, "1"}},
+		// 括弧はParenExpressionになり、親のノードの範囲にも含まれる
+		{"(1 + 2)", []string{"(1 + 2)", "(1 + 2)", "(1 + 2)", "1 + 2", "1", "2"}},
+		{"(a + b) * c", []string{"(a + b) * c", "(a + b) * c", "(a + b) * c", "(a + b)", "a + b", "a", "b", "c"}},
+		{"-(a + b)", []string{"-(a + b)", "-(a + b)", "-(a + b)", "(a + b)", "a + b", "a", "b"}},
+		{"f((x))", []string{"f((x))", "f((x))", "f((x))", "f", "(x)", "x"}},
+		{"a; b", []string{"a; b", "a", "a", "b", "b"}},
+	}
+
+	for _, tt := range tests {
+		p, program := parseProgram(t, tt.input)
+		checkParseErrors(t, p)
+
+		var got []string
+		ast.Inspect(program, func(n ast.Node) bool {
+			if n != nil {
+				got = append(got, tt.input[n.Pos().Offset:n.End().Offset])
+			}
+			return true
+		})
+
+		if len(got) != len(tt.expected) {
+			t.Errorf("input %q: wrong number of nodes. expected=%q, got=%q", tt.input, tt.expected, got)
+			continue
+		}
+		for i := range tt.expected {
+			if got[i] != tt.expected[i] {
+				t.Errorf("input %q: node %d span wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], got[i])
+			}
+		}
+	}
+}
+
+func TestNodeSpanPositions(t *testing.T) {
+	input := "let a = 1;\nlet b = [\n  a,\n];"
+	_, program := parseProgram(t, input)
+
+	tests := []struct {
+		node          ast.Node
+		pos, end      string
+		offset, width int
+	}{
+		{program, "1:1", "4:2", 0, len(input) - 1},
+		{program.Statements[0], "1:1", "1:10", 0, 9},
+		{program.Statements[1], "2:1", "4:2", 11, len(input) - 12},
+		{program.Statements[1].(*ast.LetStatement).Value, "2:9", "4:2", 19, len(input) - 20},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.pos || tt.node.End().String() != tt.end {
+			t.Errorf("tests[%d]: span wrong. expected=%s-%s, got=%s-%s", i, tt.pos, tt.end, tt.node.Pos(), tt.node.End())
+		}
+		if tt.node.Pos().Offset != tt.offset || tt.node.End().Offset-tt.node.Pos().Offset != tt.width {
+			t.Errorf("tests[%d]: offsets wrong. expected=%d+%d, got=%d-%d", i, tt.offset, tt.width, tt.node.Pos().Offset, tt.node.End().Offset)
+		}
+	}
+}
+
+// 構文エラーのノードも、読み飛ばしたトークンまでの範囲を持つ
+func TestBadNodeSpans(t *testing.T) {
+	input := "let = 5 + 5; x"
+	_, program := parseProgram(t, input)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements wrong. expected=2, got=%d", len(program.Statements))
+	}
+	bad, ok := program.Statements[0].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.BadStatement. got=%T", program.Statements[0])
+	}
+	if got := input[bad.Pos().Offset:bad.End().Offset]; got != "let = 5 + 5;" {
+		t.Errorf("bad statement span wrong. got=%q", got)
+	}
+}
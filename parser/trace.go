@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Option 構文解析器の設定
+type Option func(*Parser)
+
+// WithTrace 構文解析関数の開始(BEGIN)と終了(END)を、呼び出しの深さに応じて字下げしてwに書き出す
+// 優先順位の問題を調べる時に使う
+//
+//	BEGIN parseExpression(LOWEST) 1:1 INT "1"
+//	  BEGIN parseIntegerLiteral 1:1 INT "1"
+//	  END parseIntegerLiteral
+//	  ...
+func WithTrace(w io.Writer) Option {
+	return func(p *Parser) {
+		p.traceOut = w
+	}
+}
+
+// precedenceNames トレースで表示する優先順位の名前
+var precedenceNames = map[int]string{
+	LOWEST:      "LOWEST",
+	ASSIGN:      "ASSIGN",
+	LOGICAL_OR:  "LOGICAL_OR",
+	LOGICAL_AND: "LOGICAL_AND",
+	EQUALS:      "EQUALS",
+	LESSGREATER: "LESSGREATER",
+	SUM:         "SUM",
+	PRODUCT:     "PRODUCT",
+	PREFIX:      "PREFIX",
+	POWER:       "POWER",
+	CALL:        "CALL",
+	INDEX:       "INDEX",
+}
+
+func precedenceName(precedence int) string {
+	if name, ok := precedenceNames[precedence]; ok {
+		return name
+	}
+	// 名前のない優先順位はそのまま数値で表示する
+	return fmt.Sprint(precedence)
+}
+
+// trace 構文解析関数の開始を書き出して字下げを一段深くする
+// untraceと組にして defer p.untrace(p.trace("parseXxx")) のように使う
+func (p *Parser) trace(name string) string {
+	if p.traceOut == nil {
+		return name
+	}
+	fmt.Fprintf(p.traceOut, "%sBEGIN %s %s %s %q\n", p.traceIndent(), name, p.curToken.Pos, p.curToken.Type, p.curToken.Literal)
+	p.traceLevel++
+	return name
+}
+
+func (p *Parser) untrace(name string) {
+	if p.traceOut == nil {
+		return
+	}
+	p.traceLevel--
+	fmt.Fprintf(p.traceOut, "%sEND %s\n", p.traceIndent(), name)
+}
+
+func (p *Parser) traceIndent() string {
+	return strings.Repeat("  ", p.traceLevel)
+}
@@ -0,0 +1,467 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInterrupt Ctrl-Cで入力が中断された
+var ErrInterrupt = errors.New("interrupted")
+
+// lineReader プロンプトを表示して1行読み込む
+// 入力が終わった場合はio.EOFを返す
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// newLineReader inが端末であれば行編集できるreaderを、そうでなければbufio.Scannerを使うreaderを返す
+// completeはTabで補完する候補を返す
+func newLineReader(in io.Reader, out io.Writer, complete func() []string) lineReader {
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		return &terminalReader{
+			fd: int(f.Fd()),
+			editor: &editor{
+				in:       bufio.NewReader(f),
+				out:      out,
+				history:  loadHistory(defaultHistoryPath()),
+				complete: complete,
+			},
+		}
+	}
+	return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// scannerReader 端末ではない入力(パイプやファイル、テスト)を1行ずつ読み込む
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (s *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(s.out, prompt)
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return s.scanner.Text(), nil
+}
+
+// terminalReader 読み込んでいる間だけ端末をrawモードにして行編集する
+type terminalReader struct {
+	fd     int
+	editor *editor
+}
+
+func (t *terminalReader) ReadLine(prompt string) (string, error) {
+	state, err := makeRaw(t.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore(t.fd, state)
+
+	return t.editor.readLine(prompt)
+}
+
+// キーの入力
+// 制御文字はそのままの値、エスケープシーケンスは負の値で表す
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+
+	keyUnknown = -(iota + 1)
+	keyUp
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+)
+
+// editor rawモードの端末で1行を編集する
+// Emacs風のキー操作、履歴(上下キーとCtrl-Rの逆方向検索)、Tabによる補完に対応する
+// 入出力はio.Reader/io.Writerなので端末がなくてもテストできる
+// 端末の幅を超える長い行の折り返しは考慮していない
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete func() []string
+
+	prompt string
+	buf    []rune // 編集中の行
+	pos    int    // カーソルの位置(bufのインデックス)
+}
+
+// readLine Enterが押されるまで編集し、入力した行を履歴に追加して返す
+func (e *editor) readLine(prompt string) (string, error) {
+	e.prompt, e.buf, e.pos = prompt, nil, 0
+
+	// 履歴を辿っている位置と、辿り始める前に編集していた行
+	histIdx := len(e.history.entries)
+	saved := ""
+
+	e.refresh()
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case keyEnter, keyLF:
+			return e.accept(), nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", ErrInterrupt
+		case keyCtrlD:
+			// 空行でのCtrl-Dは入力の終了
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteRune()
+		case keyBackspace, keyCtrlH:
+			if e.pos > 0 {
+				e.pos--
+				e.deleteRune()
+			}
+		case keyDelete:
+			e.deleteRune()
+		case keyLeft, keyCtrlB:
+			if e.pos > 0 {
+				e.pos--
+			}
+		case keyRight, keyCtrlF:
+			if e.pos < len(e.buf) {
+				e.pos++
+			}
+		case keyHome, keyCtrlA:
+			e.pos = 0
+		case keyEnd, keyCtrlE:
+			e.pos = len(e.buf)
+		case keyUp, keyCtrlP:
+			if histIdx > 0 {
+				if histIdx == len(e.history.entries) {
+					saved = string(e.buf)
+				}
+				histIdx--
+				e.setLine(e.history.entries[histIdx])
+			}
+		case keyDown, keyCtrlN:
+			if histIdx < len(e.history.entries) {
+				histIdx++
+				if histIdx == len(e.history.entries) {
+					e.setLine(saved)
+				} else {
+					e.setLine(e.history.entries[histIdx])
+				}
+			}
+		case keyCtrlK:
+			e.buf = e.buf[:e.pos]
+		case keyCtrlU:
+			e.buf = append([]rune{}, e.buf[e.pos:]...)
+			e.pos = 0
+		case keyCtrlW:
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+			e.pos = start
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case keyTab:
+			e.completeWord()
+		case keyCtrlR:
+			submit, err := e.reverseSearch()
+			if err != nil {
+				return "", err
+			}
+			if submit {
+				return e.accept(), nil
+			}
+		default:
+			if key >= ' ' && unicode.IsPrint(key) {
+				e.insert([]rune{key})
+			}
+		}
+		e.refresh()
+	}
+}
+
+// accept 編集を終えて改行し、行を履歴に追加する
+func (e *editor) accept() string {
+	io.WriteString(e.out, "\r\n")
+	line := string(e.buf)
+	e.history.add(line)
+	return line
+}
+
+// readKey 1文字読み込み、エスケープシーケンスはキーの値に変換する
+func (e *editor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+
+	// ESC [ <params> <final> か ESC O <final>
+	next, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if next != '[' && next != 'O' {
+		e.in.UnreadRune()
+		return keyEscape, nil
+	}
+
+	var params []rune
+	for {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if c >= 0x40 && c <= 0x7e {
+			return escapeKey(c, string(params)), nil
+		}
+		params = append(params, c)
+	}
+}
+
+func escapeKey(final rune, params string) rune {
+	switch final {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		return keyRight
+	case 'D':
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch params {
+		case "1", "7":
+			return keyHome
+		case "4", "8":
+			return keyEnd
+		case "3":
+			return keyDelete
+		}
+	}
+	return keyUnknown
+}
+
+func (e *editor) insert(runes []rune) {
+	buf := make([]rune, 0, len(e.buf)+len(runes))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, runes...)
+	buf = append(buf, e.buf[e.pos:]...)
+	e.buf = buf
+	e.pos += len(runes)
+}
+
+// deleteRune カーソル位置の文字を削除する
+func (e *editor) deleteRune() {
+	if e.pos < len(e.buf) {
+		e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+	}
+}
+
+func (e *editor) setLine(line string) {
+	e.buf = []rune(line)
+	e.pos = len(e.buf)
+}
+
+// refresh 行を書き直し、カーソルを編集位置に移動する
+func (e *editor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K\r", e.prompt, string(e.buf))
+	if col := stringWidth(e.prompt) + stringWidth(string(e.buf[:e.pos])); col > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dC", col)
+	}
+}
+
+// completeWord カーソルの直前の単語を補完する
+// 候補が一つなら単語を完成させ、複数なら共通部分まで補完するか候補を一覧表示する
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+
+	start := e.pos
+	for start > 0 && isWordRune(e.buf[start-1]) {
+		start--
+	}
+	prefix := string(e.buf[start:e.pos])
+	if prefix == "" {
+		return
+	}
+
+	matches := completions(prefix, e.complete())
+	switch len(matches) {
+	case 0:
+		io.WriteString(e.out, "\a")
+	case 1:
+		e.insert([]rune(matches[0][len(prefix):]))
+	default:
+		common := commonPrefix(matches)
+		if len(common) > len(prefix) {
+			e.insert([]rune(common[len(prefix):]))
+			return
+		}
+		io.WriteString(e.out, "\r\n"+strings.Join(matches, "  ")+"\r\n")
+	}
+}
+
+// reverseSearch Ctrl-Rによる履歴の逆方向インクリメンタル検索
+// Enterで見つかった行を入力として確定する場合はtrueを返す
+// Ctrl-G/Ctrl-Cで検索前の行に戻り、その他の制御キーでは見つかった行を編集に戻す
+func (e *editor) reverseSearch() (bool, error) {
+	entries := e.history.entries
+	origBuf, origPos := e.buf, e.pos
+
+	var query []rune
+	idx := len(entries) - 1
+	match := ""
+	found := true
+
+	search := func(from int) {
+		for i := from; i >= 0; i-- {
+			if strings.Contains(entries[i], string(query)) {
+				idx, match, found = i, entries[i], true
+				return
+			}
+		}
+		found = false
+	}
+
+	for {
+		label := "reverse-i-search"
+		if !found {
+			label = "failing reverse-i-search"
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", label, string(query), match)
+
+		key, err := e.readKey()
+		if err != nil {
+			return false, err
+		}
+
+		switch key {
+		case keyCtrlR:
+			// 同じ検索語でさらに古い履歴を探す
+			if len(query) > 0 && idx > 0 {
+				search(idx - 1)
+			}
+		case keyBackspace, keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				search(len(entries) - 1)
+			}
+		case keyCtrlG, keyCtrlC:
+			e.buf, e.pos = origBuf, origPos
+			return false, nil
+		case keyEnter, keyLF:
+			if found && match != "" {
+				e.setLine(match)
+			}
+			return true, nil
+		default:
+			if key >= ' ' && unicode.IsPrint(key) {
+				query = append(query, key)
+				search(idx)
+				continue
+			}
+			if found && match != "" {
+				e.setLine(match)
+			}
+			return false, nil
+		}
+	}
+}
+
+// completions prefixで始まる候補を重複なしで辞書順に返す
+func completions(prefix string, candidates []string) []string {
+	seen := make(map[string]bool)
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) && !seen[c] {
+			seen[c] = true
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// stringWidth 端末に表示した時の幅
+// 日本語などの全角文字は2文字分として数える
+func stringWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if isWide(r) {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+func isWide(r rune) bool {
+	if 0xFF61 <= r && r <= 0xFF9F { // 半角カナ
+		return false
+	}
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		0x3000 <= r && r <= 0x303F || // CJKの記号と句読点
+		0xFF00 <= r && r <= 0xFF60 || 0xFFE0 <= r && r <= 0xFFE6 || // 全角形
+		0x1F300 <= r && r <= 0x1FAFF // 絵文字
+}
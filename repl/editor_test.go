@@ -0,0 +1,219 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestEditor(keys string, entries []string) *editor {
+	return &editor{
+		in:      bufio.NewReader(strings.NewReader(keys)),
+		out:     &bytes.Buffer{},
+		history: &history{entries: entries},
+		complete: func() []string {
+			return []string{"false", "fn", "foo", "foobar", "let", "変数"}
+		},
+	}
+}
+
+func TestEditorReadLine(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"let x = 1\r", "let x = 1"},
+		{"1 + 2\n", "1 + 2"},
+		{"変数 = 1\r", "変数 = 1"},
+		// 左右の移動と挿入
+		{"ac\x1b[Db\r", "abc"},
+		{"ac\x02b\r", "abc"},
+		{"bc\x01a\x05d\r", "abcd"},
+		{"bc\x1b[Ha\x1b[Fd\r", "abcd"},
+		{"ab\x1b[D\x1b[Cc\r", "abc"},
+		// 削除
+		{"abc\x7f\r", "ab"},
+		{"abc\x08\x08\r", "a"},
+		{"abc\x01\x1b[3~\r", "bc"},
+		{"abc\x01\x04\r", "bc"},
+		{"abc def\x02\x02\x0b\r", "abc d"},
+		{"abc def\x02\x02\x15\r", "ef"},
+		{"let abc def\x17\r", "let abc "},
+		{"let abc   \x17\r", "let "},
+		// 制御文字は挿入しない
+		{"a\x00b\r", "ab"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys, nil)
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("keys %q - unexpected error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q - line wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestEditorEOFAndInterrupt(t *testing.T) {
+	e := newTestEditor("\x04", nil)
+	if _, err := e.readLine(PROMPT); err != io.EOF {
+		t.Errorf("Ctrl-D on empty line should return io.EOF. got=%v", err)
+	}
+
+	e = newTestEditor("abc\x03", nil)
+	if _, err := e.readLine(PROMPT); err != ErrInterrupt {
+		t.Errorf("Ctrl-C should return ErrInterrupt. got=%v", err)
+	}
+
+	e = newTestEditor("abc", nil)
+	if _, err := e.readLine(PROMPT); err != io.EOF {
+		t.Errorf("end of input should return io.EOF. got=%v", err)
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	entries := []string{"first", "second"}
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x1b[A\r", "second"},
+		{"\x1b[A\x1b[A\r", "first"},
+		{"\x1b[A\x1b[A\x1b[A\r", "first"},
+		{"\x10\x10\x0e\r", "second"},
+		// 履歴を辿る前に編集していた行に戻る
+		{"typed\x1b[A\x1b[B\r", "typed"},
+		{"\x1b[A!\r", "second!"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys, append([]string{}, entries...))
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("keys %q - unexpected error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q - line wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+
+	// 確定した行は履歴に追加される
+	e := newTestEditor("third\r\r", append([]string{}, entries...))
+	e.readLine(PROMPT)
+	e.readLine(PROMPT)
+	if len(e.history.entries) != 3 || e.history.entries[2] != "third" {
+		t.Errorf("history wrong. got=%q", e.history.entries)
+	}
+}
+
+func TestEditorReverseSearch(t *testing.T) {
+	entries := []string{"let a = 1", "puts(a)", "let b = 2"}
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x12let\r", "let b = 2"},
+		{"\x12let\x12\r", "let a = 1"},
+		{"\x12pu\r", "puts(a)"},
+		// 制御キーで見つかった行の編集に戻る
+		{"\x12put\x05!\r", "puts(a)!"},
+		// Ctrl-Gで検索前の行に戻る
+		{"x\x12let\x07y\r", "xy"},
+		// 検索語を削除すると最新の履歴から探し直す
+		{"\x12a =\x7f\x7f\x7f\x12b\r", "let b = 2"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys, append([]string{}, entries...))
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("keys %q - unexpected error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q - line wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestEditorCompletion(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"le\t\r", "let"},
+		{"1 + fa\t\r", "1 + false"},
+		{"fo\t\r", "foo"},
+		{"foob\t\r", "foobar"},
+		{"変\t\r", "変数"},
+		{"xyz\t\r", "xyz"},
+		{"\t\r", ""},
+		// 単語の途中でも補完する
+		{"(le)\x02\t\r", "(let)"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys, nil)
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("keys %q - unexpected error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q - line wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+
+	// 候補が複数あり共通部分以上に補完できない場合は一覧を表示する
+	e := newTestEditor("f\t\r", nil)
+	e.readLine(PROMPT)
+	if !strings.Contains(e.out.(*bytes.Buffer).String(), "false  fn  foo  foobar") {
+		t.Errorf("candidates not listed. got=%q", e.out.(*bytes.Buffer).String())
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, HISTORY_FILE)
+
+	h := loadHistory(path)
+	h.add("let x = 1")
+	h.add("let x = 1")
+	h.add("")
+	h.add("x + 1")
+
+	loaded := loadHistory(path)
+	if strings.Join(loaded.entries, "|") != "let x = 1|x + 1" {
+		t.Errorf("loaded history wrong. got=%q", loaded.entries)
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{">> ", 3},
+		{"変数", 4},
+		{"aあbｱ", 5},
+	}
+
+	for _, tt := range tests {
+		if stringWidth(tt.input) != tt.expected {
+			t.Errorf("stringWidth(%q) wrong. expected=%d, got=%d", tt.input, tt.expected, stringWidth(tt.input))
+		}
+	}
+}
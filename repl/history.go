@@ -0,0 +1,73 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+)
+
+// HISTORY_FILE ホームディレクトリに保存する履歴ファイルの名前
+const HISTORY_FILE = ".monkey_history"
+
+// maxHistory 読み込む履歴の最大件数
+const maxHistory = 1000
+
+// history 入力した行の履歴
+// pathが空でなければ、追加した行をファイルにも追記する
+type history struct {
+	entries []string
+	path    string
+}
+
+// defaultHistoryPath ホームディレクトリの履歴ファイル
+// ホームディレクトリが分からない場合は空文字列(保存しない)
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+// loadHistory 履歴ファイルを読み込む
+// ファイルがない場合は空の履歴から始める
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.entries = append(h.entries, scanner.Text())
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	return h
+}
+
+// add 空行と直前と同じ行は追加しない
+// ファイルへの書き込みに失敗しても入力は続けられるようにエラーは無視する
+func (h *history) add(line string) {
+	if line == "" || len(h.entries) > 0 && h.entries[len(h.entries)-1] == line {
+		return
+	}
+	h.entries = append(h.entries, line)
+
+	if h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line + "\n")
+}
@@ -1,31 +1,213 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/koolii/go-monkey/ast"
+	"github.com/koolii/go-monkey/evaluator"
 	"github.com/koolii/go-monkey/lexer"
+	"github.com/koolii/go-monkey/object"
+	"github.com/koolii/go-monkey/parser"
 	"github.com/koolii/go-monkey/token"
 )
 
 const PROMPT = ">> "
 
+// CONTINUATION_PROMPT 入力が途中で終わっていて続きを待っている間のプロンプト
+const CONTINUATION_PROMPT = ".. "
+
+// mode 入力をどのように表示するか
+type mode string
+
+const (
+	modeEval   mode = "eval"   // 評価して結果を表示する
+	modeTokens mode = "tokens" // 字句解析したトークンを表示する
+	modeAST    mode = "ast"    // 構文解析したASTを表示する
+)
+
+const HELP = `commands:
+  :eval    evaluate input and print the result (default)
+  :tokens  print the token stream of input
+  :ast     print the parsed program as String() and an indented tree
+  :ast dot print the parsed program as String() and Graphviz DOT (:ast tree to switch back)
+  :reset   clear all bindings in the environment
+  :cancel  discard the unfinished multi-line input
+  :help    show this message
+  :quit    exit the REPL
+`
+
+// session REPLの状態
+// 束縛は同じ環境に保存されるので、前の行で定義した変数を次の行で使える
+type session struct {
+	out       io.Writer
+	env       *object.Environment
+	mode      mode
+	astFormat string // ASTモードの表示形式(treeかdot)
+}
+
+// Start 入力を構文解析・評価し、結果をoutに書き込む
+// 括弧が閉じられていないなど入力が途中で終わっている場合は、
+// 続きの行を読み込んでから全体をまとめて処理する
+// : で始まる行はREPLのコマンドとして扱う(:helpを参照)
+// 出力はすべてoutに書き込むので、テストや組み込みで使える
+// inが端末の場合は行編集・履歴(~/.monkey_history)・Tabによる補完が使える
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	s := &session{out: out, mode: modeEval, astFormat: "tree"}
+	s.reset()
+	reader := newLineReader(in, out, s.completions)
+
+	// 複数行にわたる入力の読み込み済みの行
+	var lines []string
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		prompt := PROMPT
+		if len(lines) > 0 {
+			prompt = CONTINUATION_PROMPT
+		}
+		line, err := reader.ReadLine(prompt)
+		if err == ErrInterrupt {
+			// Ctrl-Cは途中の入力を破棄する
+			lines = nil
+			continue
+		}
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+		command := strings.TrimSpace(line)
+		if len(lines) == 0 && strings.HasPrefix(command, ":") {
+			if quit := s.command(command); quit {
+				return
+			}
+			continue
+		}
+		if len(lines) > 0 && command == ":cancel" {
+			lines = nil
+			continue
+		}
+
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+
+		l := lexer.New(input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if p.Incomplete() {
+			continue
+		}
+		lines = nil
+
+		s.run(input, program, p.ParseErrors())
+	}
+}
+
+// reset 環境を作り直してすべての束縛を消す
+// putsの出力先は環境ごとに持つので、他のREPLの出力と混ざらない
+func (s *session) reset() {
+	s.env = object.NewEnvironment()
+	s.env.SetOutput(s.out)
+}
+
+// completions Tabで補完する候補(予約語、組み込み関数と環境に束縛されている名前)
+func (s *session) completions() []string {
+	candidates := append(token.Keywords(), evaluator.BuiltinNames()...)
+	return append(candidates, s.env.Names()...)
+}
+
+// command REPLのコマンドを実行する
+// :quitの場合はtrueを返す
+func (s *session) command(line string) bool {
+	// :ast tree / :ast dot は表示形式も切り替える
+	if format := strings.TrimPrefix(line, ":ast "); format != line {
+		format = strings.TrimSpace(format)
+		if format != "tree" && format != "dot" {
+			fmt.Fprintf(s.out, "unknown AST format: %s (want tree or dot)\n", format)
+			return false
+		}
+		s.astFormat = format
+		line = ":ast"
+	}
+
+	switch line {
+	case ":eval":
+		s.mode = modeEval
+	case ":tokens":
+		s.mode = modeTokens
+	case ":ast":
+		s.mode = modeAST
+	case ":reset":
+		s.reset()
+		io.WriteString(s.out, "environment cleared\n")
+		return false
+	case ":help":
+		io.WriteString(s.out, HELP)
+		return false
+	case ":quit":
+		return true
+	default:
+		fmt.Fprintf(s.out, "unknown command: %s (type :help for a list of commands)\n", line)
+		return false
+	}
+
+	if s.mode == modeAST {
+		fmt.Fprintf(s.out, "mode: %s (%s)\n", s.mode, s.astFormat)
+		return false
+	}
+	fmt.Fprintf(s.out, "mode: %s\n", s.mode)
+	return false
+}
+
+// run 現在のモードに応じて入力を処理する
+func (s *session) run(input string, program *ast.Program, errors []*parser.ParseError) {
+	if s.mode == modeTokens {
+		s.printTokens(input)
+		return
+	}
+
+	if len(errors) != 0 {
+		printParserErrors(s.out, input, errors)
+		return
+	}
+
+	if s.mode == modeAST {
+		io.WriteString(s.out, program.String())
+		io.WriteString(s.out, "\n")
+		if s.astFormat == "dot" {
+			ast.WriteDOT(s.out, program)
+		} else {
+			ast.WriteTree(s.out, program)
+		}
+		return
+	}
+
+	evaluated := evaluator.Eval(program, s.env)
+	// let文などは値を生成しないので何も表示しない
+	if evaluated != nil {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+}
+
+// printTokens 位置・種類・リテラルを1行に1トークンずつ表示する
+func (s *session) printTokens(input string) {
+	l := lexer.New(input, lexer.WithComments())
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%s\t%-8s %q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+	for _, err := range l.Errors() {
+		fmt.Fprintf(s.out, "lexer error: %s\n", err)
+	}
+}
 
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Printf("%+v\n", tok)
+// printParserErrors エラーごとにメッセージと、問題の箇所を示した行を表示する
+func printParserErrors(out io.Writer, input string, errors []*parser.ParseError) {
+	io.WriteString(out, "parser errors:\n")
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
+		if snippet := err.Snippet(input); snippet != "" {
+			io.WriteString(out, "\t"+strings.ReplaceAll(snippet, "\n", "\n\t")+"\n")
 		}
 	}
 }
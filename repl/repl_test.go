@@ -0,0 +1,290 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/koolii/go-monkey/object"
+)
+
+func TestStart(t *testing.T) {
+	input := `let x = 5;
+x * 2
+let add = fn(a, b) { a + b };
+add(x, 10)
+"mon" + "key"
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := `>> >> 10
+>> >> 15
+>> monkey
+>> `
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestStartErrors(t *testing.T) {
+	input := `let = 1;
+5 + true
+let y = 1
+y
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> parser errors:\n" +
+		"\t1:5: expected next token to be IDENT, got = instead\n" +
+		"\tlet = 1;\n" +
+		"\t    ^\n" +
+		">> ERROR: 1:3: type mismatch: INTEGER + BOOLEAN\n" +
+		">> >> 1\n" +
+		">> "
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestModes(t *testing.T) {
+	input := `:tokens
+let x = 1 + 2; // comment
+:ast
+-a * b
+:eval
+let x = 10
+x
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := `>> mode: tokens
+>> 1:1	LET      "let"
+1:5	IDENT    "x"
+1:7	=        "="
+1:9	INT      "1"
+1:11	+        "+"
+1:13	INT      "2"
+1:14	;        ";"
+1:16	COMMENT  "// comment"
+>> mode: ast (tree)
+>> ((-a) * b)
+Program
+` + "`-- ExpressionStatement \"-\"\n" +
+		"    `-- InfixExpression \"*\"\n" +
+		"        |-- PrefixExpression \"-\"\n" +
+		"        |   `-- Identifier \"a\"\n" +
+		"        `-- Identifier \"b\"\n" +
+		`>> mode: eval
+>> >> 10
+>> `
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	input := `let x = 1
+:reset
+x
+:foo
+:help
+:quit
+x
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> >> environment cleared\n" +
+		">> ERROR: 1:1: identifier not found: x\n" +
+		">> unknown command: :foo (type :help for a list of commands)\n" +
+		">> " + HELP +
+		">> "
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestIfAndFunctionTree(t *testing.T) {
+	input := `:ast
+if (x) { f(1) } else { fn(a) { a += 1 } }
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := "Program\n" +
+		"`-- ExpressionStatement \"if\"\n" +
+		"    `-- IfExpression \"if\"\n" +
+		"        |-- Identifier \"x\"\n" +
+		"        |-- BlockStatement \"{\"\n" +
+		"        |   `-- ExpressionStatement \"f\"\n" +
+		"        |       `-- CallExpression \"(\"\n" +
+		"        |           |-- Identifier \"f\"\n" +
+		"        |           `-- IntegerLiteral \"1\"\n" +
+		"        `-- BlockStatement \"{\"\n" +
+		"            `-- ExpressionStatement \"fn\"\n" +
+		"                `-- FunctionLiteral \"fn\"\n" +
+		"                    |-- Identifier \"a\"\n" +
+		"                    `-- BlockStatement \"{\"\n" +
+		"                        `-- ExpressionStatement \"a\"\n" +
+		"                            `-- AssignExpression \"+=\"\n" +
+		"                                |-- Identifier \"a\"\n" +
+		"                                `-- IntegerLiteral \"1\"\n"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("output does not contain tree.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestArrayTree(t *testing.T) {
+	input := `:ast
+[1, a][0] + a[1:]
+{"k": a}
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := "Program\n" +
+		"`-- ExpressionStatement \"[\"\n" +
+		"    `-- InfixExpression \"+\"\n" +
+		"        |-- IndexExpression \"[\"\n" +
+		"        |   |-- ArrayLiteral \"[\"\n" +
+		"        |   |   |-- IntegerLiteral \"1\"\n" +
+		"        |   |   `-- Identifier \"a\"\n" +
+		"        |   `-- IntegerLiteral \"0\"\n" +
+		"        `-- SliceExpression \"[\"\n" +
+		"            |-- Identifier \"a\"\n" +
+		"            `-- IntegerLiteral \"1\"\n"
+	expectedHash := "Program\n" +
+		"`-- ExpressionStatement \"{\"\n" +
+		"    `-- HashLiteral \"{\"\n" +
+		"        |-- StringLiteral \"k\"\n" +
+		"        `-- Identifier \"a\"\n"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("output does not contain tree.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+	if !strings.Contains(out.String(), expectedHash) {
+		t.Errorf("output does not contain tree.\nexpected=%q\ngot=%q", expectedHash, out.String())
+	}
+}
+
+func TestDOTMode(t *testing.T) {
+	input := `:ast dot
+1 + 2
+:ast svg
+:ast tree
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := `>> mode: ast (dot)
+>> (1 + 2)
+digraph AST {
+	ordering=out;
+	node [shape=box, fontname="monospace"];
+	n0 [label="Program"];
+	n1 [label="ExpressionStatement \"1\""];
+	n0 -> n1;
+	n2 [label="InfixExpression \"+\""];
+	n1 -> n2;
+	n3 [label="IntegerLiteral \"1\""];
+	n2 -> n3;
+	n4 [label="IntegerLiteral \"2\""];
+	n2 -> n4;
+}
+>> unknown AST format: svg (want tree or dot)
+>> mode: ast (tree)
+>> `
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	input := `puts("hello", [1, 2])
+len("monkey")
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	// putsの出力もoutに書き込まれる
+	expected := ">> hello\n[1, 2]\nnull\n>> 6\n>> "
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+// putsの出力先はREPLごとの環境に持つので、同時に動いている別のREPLの出力と混ざらない
+func TestConcurrentSessionsPuts(t *testing.T) {
+	inputs := []string{"puts(\"a\")\nputs(1)\n", "puts(\"b\")\nputs(2)\n"}
+	outputs := make([]bytes.Buffer, len(inputs))
+
+	var wg sync.WaitGroup
+	for i := range inputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			Start(strings.NewReader(inputs[i]), &outputs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	expected := []string{
+		">> a\nnull\n>> 1\nnull\n>> ",
+		">> b\nnull\n>> 2\nnull\n>> ",
+	}
+	for i := range expected {
+		if outputs[i].String() != expected[i] {
+			t.Errorf("outputs[%d] wrong.\nexpected=%q\ngot=%q", i, expected[i], outputs[i].String())
+		}
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	s := &session{env: object.NewEnvironment()}
+	s.env.Set("myVar", &object.Integer{Value: 1})
+
+	candidates := strings.Join(s.completions(), " ")
+	for _, want := range []string{"let", "fn", "len", "puts", "myVar"} {
+		if !strings.Contains(" "+candidates+" ", " "+want+" ") {
+			t.Errorf("completions do not contain %q. got=%q", want, candidates)
+		}
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x + y
+};
+add(1,
+  2)
+"multi
+line"
+let z = (1 +
+:cancel
+1 + + 2
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> .. .. " +
+		">> .. 3\n" +
+		">> .. multi\nline\n" +
+		">> .. " +
+		">> parser errors:\n\t1:5: no prefix parse function for + found\n\t1 + + 2\n\t    ^\n" +
+		">> "
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package repl
+
+import "errors"
+
+// termState rawモードに対応していない環境では使われない
+type termState struct{}
+
+// isTerminal rawモードに対応していない環境では常にfalseとし、行単位の読み込みを使う
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (*termState, error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func restore(fd int, state *termState) error {
+	return nil
+}
@@ -1,12 +1,39 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 // TokenType is alias of string
 type TokenType string
 
+// Position ソースコード上の位置
+// Offsetはバイト単位、Line/Columnは1始まり
+type Position struct {
+	Offset int // 入力先頭からのバイトオフセット(0始まり)
+	Line   int // 行番号(1始まり)
+	Column int // 列番号(1始まり)
+}
+
+// IsValid Line が設定されているかどうか(ゼロ値は無効な位置)
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String is "line:column" format
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 // Token is keyword type of Monkey
+// Posはトークンの開始位置、Endはトークンの直後の位置(終端は含まない)
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+	End     Position
 }
 
 const (
@@ -14,11 +41,17 @@ const (
 	ILLEGAL = "ILLEGAL"
 	// EOF どこで読み込みを停止するか構文解析器に伝える
 	EOF = "EOF"
+	// COMMENT コメント(字句解析器のオプションを指定した時だけ返される)
+	COMMENT = "COMMENT" // // foo, /* bar */
 
 	// IDENT literal
 	IDENT = "IDENT" // add, foobar, x, y...
 	// INT number
-	INT = "INT" // 23142432
+	INT = "INT" // 23142432, 0xFF, 0o755, 0b1010, 1_000_000
+	// FLOAT number
+	FLOAT = "FLOAT" // 3.14, 1e-9
+	// STRING literal
+	STRING = "STRING" // "foobar"
 
 	// ASSIGN define various
 	ASSIGN = "="
@@ -28,19 +61,44 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
+
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
+
+	// AND/OR 論理演算子(短絡評価)
+	AND = "&&"
+	OR  = "||"
+
+	// ビット演算子
+	BIT_AND     = "&"
+	BIT_OR      = "|"
+	BIT_XOR     = "^"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
 
-	LT = "<"
-	GT = ">"
+	// 複合代入演算子
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
 
 	// COMMA delimiter
 	COMMA = ","
 	// SEMICOLON end of line
 	SEMICOLON = ";"
+	// COLON スライスの区切り arr[1:3]
+	COLON = ":"
 
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "{"
-	RBRACE = "}"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACE   = "{"
+	RBRACE   = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// keyword
 	FUNCTION = "FUNCTION"
@@ -73,3 +131,14 @@ func LookupIdent(ident string) TokenType {
 	// それ以外は変数として定義
 	return IDENT
 }
+
+// Keywords 予約語の一覧(辞書順)
+// REPLの補完などで使う
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}